@@ -1,50 +1,389 @@
 package malgova
 
+import (
+	"sort"
+	"time"
+
+	"github.com/sivamgr/kstreamdb"
+)
+
 // AllocateCash book
 func (b *Book) AllocateCash(Money float64) {
 	b.CashAllocated = Money
 	b.Cash = b.CashAllocated
 }
 
-// PlaceMarketOrder book
-func (b *Book) placeMarketOrder(Qty int) {
-	b.PendingOrderQuantity = Qty
-	b.IsMarketOrder = true
+// orderSpec returns the order for the signed quantity, negative for sells
+func orderSpec(symbol string, Qty int, orderType OrderType, Price float64, TriggerPrice float64) Order {
+	o := Order{
+		Symbol:       symbol,
+		Side:         SideBuy,
+		Type:         orderType,
+		Quantity:     Qty,
+		Price:        Price,
+		TriggerPrice: TriggerPrice,
+	}
+	if Qty < 0 {
+		o.Side = SideSell
+		o.Quantity = -Qty
+	}
+	return o
+}
+
+// placeOrder adds a working order to the book as specified, returns the order id
+func (b *Book) placeOrder(spec Order) int {
+	if b.orders == nil {
+		b.orders = make(map[int]*Order)
+	}
+	b.lastOrderID++
+	o := &Order{
+		ID:           b.lastOrderID,
+		Symbol:       spec.Symbol,
+		Side:         spec.Side,
+		Type:         spec.Type,
+		Quantity:     spec.Quantity,
+		Price:        spec.Price,
+		TriggerPrice: spec.TriggerPrice,
+		TimeInForce:  spec.TimeInForce,
+		ExpireAt:     spec.ExpireAt,
+		ParentID:     spec.ParentID,
+		Status:       OrderStatusOpen,
+		PlacedAt:     b.clock,
+		UpdatedAt:    b.clock,
+	}
+	if o.Symbol == "" {
+		o.Symbol = b.symbol
+	}
+	b.orders[o.ID] = o
+	if err := b.validate(o); err != nil {
+		b.reject(o, err)
+		return o.ID
+	}
+	b.working = append(b.working, o)
+	if b.portfolio != nil {
+		b.portfolio.update(b)
+	}
+	b.notify(OrderAccepted, o, 0, 0)
+	return o.ID
+}
+
+// notify queues the order update for the strategy
+func (b *Book) notify(event OrderEvent, o *Order, fillQty int, fillPrice float64) {
+	b.updates = append(b.updates, OrderUpdate{
+		Event:        event,
+		Order:        *o,
+		At:           b.clock,
+		FillQuantity: fillQty,
+		FillPrice:    fillPrice,
+	})
+}
+
+// notifyFill queues the fill update with the charges levied
+func (b *Book) notifyFill(event OrderEvent, o *Order, fillQty int, fillPrice float64, charges float64) {
+	b.notify(event, o, fillQty, fillPrice)
+	b.updates[len(b.updates)-1].Charges = charges
+}
+
+// popUpdates returns the queued order updates
+func (b *Book) popUpdates() []OrderUpdate {
+	updates := b.updates
+	b.updates = nil
+	return updates
 }
 
 // PlaceMarketOrder book
-func (b *Book) placeLimitOrder(Qty int, Price float64) {
-	b.PendingOrderQuantity = Qty
-	b.IsMarketOrder = false
-	b.PendingOrderPrice = Price
+func (b *Book) placeMarketOrder(symbol string, Qty int) int {
+	return b.placeOrder(orderSpec(symbol, Qty, OrderTypeMarket, 0, 0))
+}
+
+// PlaceLimitOrder book
+func (b *Book) placeLimitOrder(symbol string, Qty int, Price float64) int {
+	return b.placeOrder(orderSpec(symbol, Qty, OrderTypeLimit, Price, 0))
+}
+
+// placeStopOrder book, stop-market if the limit price is zero
+func (b *Book) placeStopOrder(symbol string, Qty int, TriggerPrice float64, Price float64) int {
+	if Price > 0 {
+		return b.placeOrder(orderSpec(symbol, Qty, OrderTypeStopLimit, Price, TriggerPrice))
+	}
+	return b.placeOrder(orderSpec(symbol, Qty, OrderTypeStopMarket, 0, TriggerPrice))
+}
+
+// purgeWorkingOrders drops the completed orders from the working list
+func (b *Book) purgeWorkingOrders() {
+	working := b.working[:0]
+	for _, o := range b.working {
+		if o.IsOpen() {
+			working = append(working, o)
+		}
+	}
+	b.working = working
+}
+
+// updateQuote keeps the last tick of the symbol, used for order validation
+func (b *Book) updateQuote(t kstreamdb.TickData) {
+	if b.quotes == nil {
+		b.quotes = make(map[string]kstreamdb.TickData)
+	}
+	b.quotes[t.TradingSymbol] = t
 }
 
-// QuantityAffordable book
+// quoteOf returns the last tick of the symbol
+func (b *Book) quoteOf(symbol string) kstreamdb.TickData {
+	return b.quotes[symbol]
+}
+
+// setClock updates the book time, used for order timestamps
+func (b *Book) setClock(t time.Time) {
+	b.clock = t
+}
+
+// QuantityAffordable book, in multiples of lot size
 func (b *Book) QuantityAffordable(Price float64) int {
-	if Price <= b.Cash {
-		return int(b.Cash / Price)
+	return b.QuantityAffordableOf(b.symbol, Price)
+}
+
+// QuantityAffordableOf the symbol, in multiples of its lot size
+func (b *Book) QuantityAffordableOf(symbol string, Price float64) int {
+	free := b.FreeCash() * b.leverage(symbol, false)
+	if Price > 0 && Price <= free {
+		qty := int(free / Price)
+		if lot := b.lotSize(symbol); lot > 1 {
+			qty -= qty % lot
+		}
+		return qty
 	}
 	return 0
 }
 
-// Buy Order
-func (b *Book) Buy(Qty int) {
-	b.placeMarketOrder(Qty)
+// Buy Order, returns the order id
+func (b *Book) Buy(Qty int) int {
+	return b.placeMarketOrder(b.symbol, Qty)
+}
+
+// Sell Order, returns the order id
+func (b *Book) Sell(Qty int) int {
+	return b.placeMarketOrder(b.symbol, -Qty)
+}
+
+// BuySymbol places a market buy order in the symbol, returns the order id
+func (b *Book) BuySymbol(symbol string, Qty int) int {
+	return b.placeMarketOrder(symbol, Qty)
+}
+
+// SellSymbol places a market sell order in the symbol, returns the order id
+func (b *Book) SellSymbol(symbol string, Qty int) int {
+	return b.placeMarketOrder(symbol, -Qty)
+}
+
+// PlaceOrder places the order specified by the Symbol, Side, Type, Quantity,
+// Price, TriggerPrice, TimeInForce and ExpireAt of the given order, the rest
+// of the fields are ignored. Symbol defaults to the instance symbol. Returns
+// the order id.
+func (b *Book) PlaceOrder(spec Order) int {
+	spec.ParentID = 0
+	return b.placeOrder(spec)
+}
+
+// BuyLimit Order, returns the order id
+func (b *Book) BuyLimit(Qty int, Price float64) int {
+	return b.placeLimitOrder(b.symbol, Qty, Price)
+}
+
+// SellLimit Order, returns the order id
+func (b *Book) SellLimit(Qty int, Price float64) int {
+	return b.placeLimitOrder(b.symbol, -Qty, Price)
+}
+
+// BuyStop places a stop-market buy order, triggered when LTP rises to the trigger price
+func (b *Book) BuyStop(Qty int, TriggerPrice float64) int {
+	return b.placeStopOrder(b.symbol, Qty, TriggerPrice, 0)
+}
+
+// SellStop places a stop-market sell order, triggered when LTP falls to the trigger price
+func (b *Book) SellStop(Qty int, TriggerPrice float64) int {
+	return b.placeStopOrder(b.symbol, -Qty, TriggerPrice, 0)
+}
+
+// BuyStopLimit places a stop-limit buy order, which turns into a limit order at Price once triggered
+func (b *Book) BuyStopLimit(Qty int, TriggerPrice float64, Price float64) int {
+	return b.placeStopOrder(b.symbol, Qty, TriggerPrice, Price)
+}
+
+// SellStopLimit places a stop-limit sell order, which turns into a limit order at Price once triggered
+func (b *Book) SellStopLimit(Qty int, TriggerPrice float64, Price float64) int {
+	return b.placeStopOrder(b.symbol, -Qty, TriggerPrice, Price)
+}
+
+// Cancel the working order
+func (b *Book) Cancel(orderID int) error {
+	o, ok := b.orders[orderID]
+	if !ok {
+		return ErrOrderNotFound
+	}
+	if !o.IsOpen() {
+		return ErrOrderNotOpen
+	}
+	b.cancelOrder(o)
+	return nil
+}
+
+// CancelAll working orders
+func (b *Book) CancelAll() {
+	for _, o := range b.working {
+		if o.IsOpen() {
+			b.cancelOrder(o)
+		}
+	}
+}
+
+// Modify the quantity and price of the working order. Qty is the new total
+// quantity, which can not go below the quantity already filled. Price is the
+// limit price, or the trigger price for stop-market orders. Zero leaves the
+// value unchanged. An order filled before the modification gets to the book
+// returns ErrOrderNotOpen.
+func (b *Book) Modify(orderID int, Qty int, Price float64) error {
+	o, ok := b.orders[orderID]
+	if !ok {
+		return ErrOrderNotFound
+	}
+	if !o.IsOpen() {
+		return ErrOrderNotOpen
+	}
+	if Qty < 0 || (Qty > 0 && Qty <= o.FilledQuantity) {
+		return ErrInvalidQuantity
+	}
+	m := *o
+	if Qty > 0 {
+		m.Quantity = Qty
+	}
+	if Price > 0 {
+		switch o.Type {
+		case OrderTypeLimit, OrderTypeStopLimit:
+			m.Price = Price
+		case OrderTypeStopMarket:
+			m.TriggerPrice = Price
+		}
+	}
+	if err := b.validate(&m); err != nil {
+		return err
+	}
+	if m.Price != o.Price || m.Quantity > o.Quantity {
+		// the exchange sends the order to the back of the queue
+		o.queued = false
+		o.QueueAhead = 0
+	}
+	o.Quantity = m.Quantity
+	o.Price = m.Price
+	o.TriggerPrice = m.TriggerPrice
+	o.UpdatedAt = b.clock
+	if b.portfolio != nil {
+		b.portfolio.update(b)
+	}
+	b.notify(OrderModified, o, 0, 0)
+	return nil
+}
+
+// SetTimeInForce of the working order, orders are DAY orders by default
+func (b *Book) SetTimeInForce(orderID int, tif TimeInForce) error {
+	o, ok := b.orders[orderID]
+	if !ok {
+		return ErrOrderNotFound
+	}
+	if !o.IsOpen() {
+		return ErrOrderNotOpen
+	}
+	if tif == TIFGTT && !o.ExpireAt.After(b.clock) {
+		return ErrInvalidExpiry
+	}
+	o.TimeInForce = tif
+	return nil
+}
+
+// SetGoodTill makes the working order a GTT order, expiring at the given time
+func (b *Book) SetGoodTill(orderID int, expireAt time.Time) error {
+	o, ok := b.orders[orderID]
+	if !ok {
+		return ErrOrderNotFound
+	}
+	if !o.IsOpen() {
+		return ErrOrderNotOpen
+	}
+	if !expireAt.After(b.clock) {
+		return ErrInvalidExpiry
+	}
+	o.TimeInForce = TIFGTT
+	o.ExpireAt = expireAt
+	return nil
 }
 
-// Sell Order
-func (b *Book) Sell(Qty int) {
-	b.placeMarketOrder(-Qty)
+// expireOrders expires the working orders past their time in force
+func (b *Book) expireOrders(sessionClosed bool) {
+	for _, o := range b.working {
+		if o.IsOpen() && o.isExpired(b.clock, sessionClosed) {
+			o.Status = OrderStatusExpired
+			o.UpdatedAt = b.clock
+			b.notify(OrderExpired, o, 0, 0)
+		}
+	}
+	b.purgeWorkingOrders()
+}
+
+func (b *Book) cancelOrder(o *Order) {
+	o.Status = OrderStatusCancelled
+	o.UpdatedAt = b.clock
+	b.notify(OrderCancelled, o, 0, 0)
+}
+
+// Order returns a copy of the order with given id
+func (b *Book) Order(orderID int) (Order, bool) {
+	if o, ok := b.orders[orderID]; ok {
+		return *o, true
+	}
+	return Order{}, false
 }
 
-// InPosition check
+// Orders returns copies of all the orders placed, ordered by id
+func (b *Book) Orders() []Order {
+	orders := make([]Order, 0, len(b.orders))
+	for _, o := range b.orders {
+		orders = append(orders, *o)
+	}
+	sort.Slice(orders, func(i, j int) bool {
+		return orders[i].ID < orders[j].ID
+	})
+	return orders
+}
+
+// WorkingOrders returns copies of the orders waiting to be filled
+func (b *Book) WorkingOrders() []Order {
+	orders := make([]Order, 0, len(b.working))
+	for _, o := range b.working {
+		if o.IsOpen() {
+			orders = append(orders, *o)
+		}
+	}
+	return orders
+}
+
+// InPosition check, true if holding a position in any symbol
 func (b *Book) InPosition() bool {
-	return (b.Position != 0)
+	for _, h := range b.holdings {
+		if h.Position != 0 {
+			return true
+		}
+	}
+	return false
 }
 
 // IsOrderWaiting check
 func (b *Book) IsOrderWaiting() bool {
-	return (b.PendingOrderQuantity != 0)
+	for _, o := range b.working {
+		if o.IsOpen() {
+			return true
+		}
+	}
+	return false
 }
 
 // IsBookClean check
@@ -52,9 +391,24 @@ func (b *Book) IsBookClean() bool {
 	return (!b.InPosition() && !b.IsOrderWaiting())
 }
 
-// Exit all position
+// Exit all position, cancelling the working orders
 func (b *Book) Exit() {
-	if b.Position != 0 {
-		b.placeMarketOrder(-b.Position)
+	b.CancelAll()
+	for _, h := range b.Holdings() {
+		if h.Position != 0 {
+			b.placeMarketOrder(h.Symbol, -h.Position)
+		}
+	}
+}
+
+// ExitSymbol exits the position in the symbol, cancelling its working orders
+func (b *Book) ExitSymbol(symbol string) {
+	for _, o := range b.working {
+		if o.IsOpen() && o.Symbol == symbol {
+			b.cancelOrder(o)
+		}
+	}
+	if pos := b.PositionOf(symbol); pos != 0 {
+		b.placeMarketOrder(symbol, -pos)
 	}
 }
@@ -0,0 +1,108 @@
+package malgova
+
+import (
+	"testing"
+
+	"github.com/sivamgr/kstreamdb"
+)
+
+func TestCancelModify(t *testing.T) {
+	tests := []struct {
+		name   string
+		place  func(b *Book)
+		change func(b *Book) error
+		want   error
+		ticks  []kstreamdb.TickData
+		check  func(t *testing.T, b *Book)
+	}{
+		{
+			name:   "cancel working order",
+			place:  func(b *Book) { b.BuyLimit(10, 99) },
+			change: func(b *Book) error { return b.Cancel(1) },
+			check: func(t *testing.T, b *Book) {
+				wantOrder(t, b, 1, OrderStatusCancelled, 0, 0)
+				if b.IsOrderWaiting() {
+					t.Error("order still waiting")
+				}
+			},
+		},
+		{
+			name:   "cancel filled order",
+			place:  func(b *Book) { b.Buy(10) },
+			change: func(b *Book) error { return b.Cancel(1) },
+			want:   ErrOrderNotOpen,
+		},
+		{
+			name:   "cancel unknown order",
+			place:  func(b *Book) {},
+			change: func(b *Book) error { return b.Cancel(7) },
+			want:   ErrOrderNotFound,
+		},
+		{
+			name:   "modify price fills at the new price",
+			place:  func(b *Book) { b.BuyLimit(10, 98) },
+			change: func(b *Book) error { return b.Modify(1, 0, 99) },
+			ticks:  []kstreamdb.TickData{testTick(2, 99, depth(98.5, 100), depth(99, 100))},
+			check: func(t *testing.T, b *Book) {
+				wantOrder(t, b, 1, OrderStatusFilled, 10, 99)
+			},
+		},
+		{
+			name:   "modify quantity",
+			place:  func(b *Book) { b.BuyLimit(10, 98) },
+			change: func(b *Book) error { return b.Modify(1, 25, 0) },
+			check: func(t *testing.T, b *Book) {
+				if o := order(t, b, 1); o.Quantity != 25 || o.Price != 98 {
+					t.Errorf("quantity %d price %.2f, want 25 at 98", o.Quantity, o.Price)
+				}
+			},
+		},
+		{
+			name:   "modify stop trigger",
+			place:  func(b *Book) { b.SellStop(10, 95) },
+			change: func(b *Book) error { return b.Modify(1, 0, 96) },
+			check: func(t *testing.T, b *Book) {
+				if o := order(t, b, 1); o.TriggerPrice != 96 {
+					t.Errorf("trigger %.2f, want 96", o.TriggerPrice)
+				}
+			},
+		},
+		{
+			name:   "modify below the filled quantity",
+			place:  func(b *Book) { b.Buy(300) },
+			change: func(b *Book) error { return b.Modify(1, 100, 0) },
+			want:   ErrInvalidQuantity,
+		},
+		{
+			name:   "modify beyond the funds keeps the order",
+			place:  func(b *Book) { b.BuyLimit(10, 98) },
+			change: func(b *Book) error { return b.Modify(1, 100000, 0) },
+			want:   ErrInsufficientFunds,
+			check: func(t *testing.T, b *Book) {
+				if o := order(t, b, 1); o.Quantity != 10 || !o.IsOpen() {
+					t.Errorf("%s quantity %d, want open 10", o.Status, o.Quantity)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var err error
+			c := tickCase{
+				script: map[int]func(b *Book){
+					0: tt.place,
+					1: func(b *Book) { err = tt.change(b) },
+				},
+				// the market order of 300 fills 200 on the second tick
+				ticks: append([]kstreamdb.TickData{quietTick(), testTick(1, 100, depth(99.5, 100), depth(100, 100, 100.5, 100))}, tt.ticks...),
+			}
+			a := c.run()
+			if err != tt.want {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+			if tt.check != nil {
+				tt.check(t, &a.book)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package malgova
+
+// bracket order legs, attached to the entry order
+type bracket struct {
+	entryID    int
+	targetID   int
+	stopLossID int
+	target     float64
+	stopLoss   float64
+	trail      float64
+	best       float64
+}
+
+// placeBracketOrder places the entry order, limit if Price is non-zero
+func (b *Book) placeBracketOrder(symbol string, Qty int, Price float64, Target float64, StopLoss float64, TrailingStop float64) int {
+	var entryID int
+	if Price > 0 {
+		entryID = b.placeLimitOrder(symbol, Qty, Price)
+	} else {
+		entryID = b.placeMarketOrder(symbol, Qty)
+	}
+	if b.brackets == nil {
+		b.brackets = make(map[int]*bracket)
+	}
+	b.brackets[entryID] = &bracket{
+		entryID:  entryID,
+		target:   Target,
+		stopLoss: StopLoss,
+		trail:    TrailingStop,
+	}
+	return entryID
+}
+
+// BuyBracket places a buy entry order with take-profit and stop-loss legs.
+// Target, StopLoss and TrailingStop are in points from the entry fill price,
+// TrailingStop of zero disables trailing. Price of zero enters at market.
+// Returns the entry order id.
+func (b *Book) BuyBracket(Qty int, Price float64, Target float64, StopLoss float64, TrailingStop float64) int {
+	return b.placeBracketOrder(b.symbol, Qty, Price, Target, StopLoss, TrailingStop)
+}
+
+// SellBracket places a sell entry order with take-profit and stop-loss legs,
+// see BuyBracket. Returns the entry order id.
+func (b *Book) SellBracket(Qty int, Price float64, Target float64, StopLoss float64, TrailingStop float64) int {
+	return b.placeBracketOrder(b.symbol, -Qty, Price, Target, StopLoss, TrailingStop)
+}
+
+// BracketLegs returns the target and stop-loss order ids of the bracket entry,
+// zero until the entry gets filled
+func (b *Book) BracketLegs(entryID int) (int, int) {
+	if br, ok := b.brackets[entryID]; ok {
+		return br.targetID, br.stopLossID
+	}
+	return 0, 0
+}
+
+// OCO groups the working orders as one-cancels-other, when any of them fills
+// the rest are cancelled. A partial fill shrinks the rest to the quantity
+// pending on the order filled. Returns the group id.
+func (b *Book) OCO(orderIDs ...int) int {
+	b.lastGroupID++
+	for _, id := range orderIDs {
+		if o, ok := b.orders[id]; ok && o.IsOpen() {
+			o.OCOGroup = b.lastGroupID
+		}
+	}
+	return b.lastGroupID
+}
+
+// settleOCO cancels the other open orders of the group once the order is
+// filled, on a partial fill the others are shrunk to its pending quantity
+func (b *Book) settleOCO(o *Order) {
+	if o.OCOGroup == 0 {
+		return
+	}
+	for _, w := range b.working {
+		if w.ID == o.ID || w.OCOGroup != o.OCOGroup || !w.IsOpen() {
+			continue
+		}
+		if o.Status == OrderStatusFilled {
+			b.cancelOrder(w)
+		} else if w.PendingQuantity() > o.PendingQuantity() {
+			w.Quantity = w.FilledQuantity + o.PendingQuantity()
+			w.UpdatedAt = b.clock
+			b.notify(OrderModified, w, 0, 0)
+		}
+	}
+}
+
+// attachBracketLegs places or grows the exit legs for the filled entry
+// quantity. Once a leg has closed, the legs for further entry fills are
+// placed afresh.
+func (b *Book) attachBracketLegs(entry *Order, qty int, price float64) {
+	br, ok := b.brackets[entry.ID]
+	if !ok {
+		return
+	}
+	if br.targetID != 0 && b.orders[br.targetID].IsOpen() && b.orders[br.stopLossID].IsOpen() {
+		for _, id := range []int{br.targetID, br.stopLossID} {
+			leg := b.orders[id]
+			leg.Quantity += qty
+			leg.UpdatedAt = b.clock
+			b.notify(OrderModified, leg, 0, 0)
+		}
+		return
+	}
+	target := orderSpec(entry.Symbol, qty, OrderTypeLimit, price-br.target, 0)
+	stopLoss := orderSpec(entry.Symbol, qty, OrderTypeStopMarket, 0, price+br.stopLoss)
+	if entry.Side == SideBuy {
+		target = orderSpec(entry.Symbol, -qty, OrderTypeLimit, price+br.target, 0)
+		stopLoss = orderSpec(entry.Symbol, -qty, OrderTypeStopMarket, 0, price-br.stopLoss)
+	}
+	target.ParentID = entry.ID
+	stopLoss.ParentID = entry.ID
+	br.targetID = b.placeOrder(target)
+	br.stopLossID = b.placeOrder(stopLoss)
+	br.best = price
+	b.OCO(br.targetID, br.stopLossID)
+}
+
+// trailBrackets moves the stop-loss legs of the symbol along with the price
+func (b *Book) trailBrackets(symbol string, ltp float64) {
+	for _, br := range b.brackets {
+		if br.trail <= 0 || br.stopLossID == 0 {
+			continue
+		}
+		sl := b.orders[br.stopLossID]
+		if sl.Symbol != symbol || !sl.IsOpen() || sl.Triggered {
+			continue
+		}
+		if sl.Side == SideSell && ltp >= br.best+br.trail {
+			br.best = ltp
+			if trigger := br.best - br.stopLoss; trigger > sl.TriggerPrice {
+				sl.TriggerPrice = trigger
+				sl.UpdatedAt = b.clock
+			}
+		} else if sl.Side == SideBuy && ltp <= br.best-br.trail {
+			br.best = ltp
+			if trigger := br.best + br.stopLoss; trigger < sl.TriggerPrice {
+				sl.TriggerPrice = trigger
+				sl.UpdatedAt = b.clock
+			}
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package malgova
+
+import (
+	"testing"
+
+	"github.com/sivamgr/kstreamdb"
+)
+
+func TestOCO(t *testing.T) {
+	exits := map[int]func(b *Book){
+		0: func(b *Book) { b.Buy(100) },
+		1: func(b *Book) { b.OCO(b.SellLimit(100, 110), b.SellStop(100, 95)) },
+	}
+	runTickCases(t, []tickCase{
+		{
+			name:   "partial fill shrinks the other order",
+			script: exits,
+			ticks: []kstreamdb.TickData{quietTick(),
+				testTick(1, 100, depth(99, 100), depth(100, 100)),
+				testTick(2, 95, depth(95, 40), depth(96, 100))},
+			check: func(t *testing.T, b *Book) {
+				wantOrder(t, b, 3, OrderStatusOpen, 40, 95)
+				if o := order(t, b, 2); !o.IsOpen() || o.Quantity != 60 {
+					t.Errorf("target %s quantity %d, want open 60", o.Status, o.Quantity)
+				}
+			},
+		},
+		{
+			name:   "full fill cancels the other order",
+			script: exits,
+			ticks: []kstreamdb.TickData{quietTick(),
+				testTick(1, 100, depth(99, 100), depth(100, 100)),
+				testTick(2, 95, depth(95, 40), depth(96, 100)),
+				testTick(3, 94, depth(94, 100), depth(95, 100))},
+			check: func(t *testing.T, b *Book) {
+				wantOrder(t, b, 3, OrderStatusFilled, 100, (95*40+94*60)/100.0)
+				wantOrder(t, b, 2, OrderStatusCancelled, 0, 0)
+				if b.Position != 0 {
+					t.Errorf("position %d, want 0", b.Position)
+				}
+			},
+		},
+	})
+}
+
+func TestBracket(t *testing.T) {
+	runTickCases(t, []tickCase{
+		{
+			name:   "legs are placed on the entry fill",
+			script: map[int]func(b *Book){0: func(b *Book) { b.BuyBracket(10, 0, 5, 3, 0) }},
+			ticks:  []kstreamdb.TickData{quietTick(), testTick(1, 100, depth(99, 100), depth(100, 100))},
+			check: func(t *testing.T, b *Book) {
+				target, stopLoss := b.BracketLegs(1)
+				if tg := order(t, b, target); !tg.IsOpen() || tg.Side != SideSell || tg.Quantity != 10 || tg.Price != 105 {
+					t.Errorf("target %+v", tg)
+				}
+				if sl := order(t, b, stopLoss); !sl.IsOpen() || sl.Side != SideSell || sl.Quantity != 10 || sl.TriggerPrice != 97 {
+					t.Errorf("stop-loss %+v", sl)
+				}
+			},
+		},
+		{
+			name:   "legs of a sell entry",
+			script: map[int]func(b *Book){0: func(b *Book) { b.SellBracket(10, 0, 5, 3, 0) }},
+			ticks:  []kstreamdb.TickData{quietTick(), testTick(1, 100, depth(100, 100), depth(101, 100))},
+			check: func(t *testing.T, b *Book) {
+				target, stopLoss := b.BracketLegs(1)
+				if tg := order(t, b, target); tg.Side != SideBuy || tg.Price != 95 {
+					t.Errorf("target %+v", tg)
+				}
+				if sl := order(t, b, stopLoss); sl.Side != SideBuy || sl.TriggerPrice != 103 {
+					t.Errorf("stop-loss %+v", sl)
+				}
+			},
+		},
+		{
+			name:   "target cancels the stop-loss",
+			script: map[int]func(b *Book){0: func(b *Book) { b.BuyBracket(10, 0, 5, 3, 0) }},
+			ticks: []kstreamdb.TickData{quietTick(),
+				testTick(1, 100, depth(99, 100), depth(100, 100)),
+				testTick(2, 105, depth(105, 100), depth(105.5, 100))},
+			check: func(t *testing.T, b *Book) {
+				wantOrder(t, b, 2, OrderStatusFilled, 10, 105)
+				wantOrder(t, b, 3, OrderStatusCancelled, 0, 0)
+				if b.Position != 0 {
+					t.Errorf("position %d, want 0", b.Position)
+				}
+			},
+		},
+		{
+			name:   "stop-loss trails the price",
+			script: map[int]func(b *Book){0: func(b *Book) { b.BuyBracket(10, 0, 10, 3, 1) }},
+			ticks: []kstreamdb.TickData{quietTick(),
+				testTick(1, 100, depth(99, 100), depth(100, 100)),
+				testTick(2, 102, depth(101.5, 100), depth(102, 100))},
+			check: func(t *testing.T, b *Book) {
+				if sl := order(t, b, 3); sl.TriggerPrice != 99 {
+					t.Errorf("stop-loss trigger %.2f, want 99", sl.TriggerPrice)
+				}
+			},
+		},
+		{
+			name:   "legs grow with the entry fills",
+			script: map[int]func(b *Book){0: func(b *Book) { b.BuyBracket(20, 0, 5, 3, 0) }},
+			ticks: []kstreamdb.TickData{quietTick(),
+				testTick(1, 100, depth(99, 100), depth(100, 10)),
+				testTick(2, 100, depth(99, 100), depth(100, 10))},
+			check: func(t *testing.T, b *Book) {
+				for _, id := range []int{2, 3} {
+					if o := order(t, b, id); !o.IsOpen() || o.Quantity != 20 {
+						t.Errorf("leg %d %s quantity %d, want open 20", id, o.Status, o.Quantity)
+					}
+				}
+			},
+			checkUpdates: func(t *testing.T, updates []OrderUpdate) {
+				modified := 0
+				for _, u := range updates {
+					if u.Event == OrderModified && u.Order.Quantity == 20 {
+						modified++
+					}
+				}
+				if modified != 2 {
+					t.Errorf("%d legs notified modified, want 2", modified)
+				}
+			},
+		},
+		{
+			name:   "legs are placed again after a leg closed",
+			config: btConfig{queuePosition: true},
+			script: map[int]func(b *Book){0: func(b *Book) { b.BuyBracket(20, 100, 5, 3, 0) }},
+			ticks: []kstreamdb.TickData{
+				traded(testTick(0, 100, depth(100, 50), depth(100.5, 50)), 1000),
+				traded(testTick(1, 100, depth(100, 50), depth(100.5, 50)), 1000),
+				traded(testTick(2, 100, depth(100, 50), depth(100.5, 50)), 1060),
+				traded(testTick(3, 105, depth(105, 10), depth(105.5, 50)), 1070),
+				traded(testTick(4, 100, depth(100, 50), depth(100.5, 50)), 1080)},
+			check: func(t *testing.T, b *Book) {
+				wantOrder(t, b, 1, OrderStatusFilled, 20, 100)
+				wantOrder(t, b, 2, OrderStatusFilled, 10, 105)
+				wantOrder(t, b, 3, OrderStatusCancelled, 0, 0)
+				target, stopLoss := b.BracketLegs(1)
+				if target != 4 || stopLoss != 5 {
+					t.Fatalf("legs %d %d, want 4 5", target, stopLoss)
+				}
+				for _, id := range []int{target, stopLoss} {
+					if o := order(t, b, id); !o.IsOpen() || o.Quantity != 10 {
+						t.Errorf("leg %d %s quantity %d, want open 10", id, o.Status, o.Quantity)
+					}
+				}
+			},
+		},
+	})
+}
@@ -1,23 +1,35 @@
 package malgova
 
 import (
+	"math/rand"
 	"reflect"
+	"sort"
 	"time"
 
 	"github.com/sivamgr/kstreamdb"
 )
 
+// portfolioInstanceSymbol names the single instance of a portfolio strategy
+const portfolioInstanceSymbol = "PORTFOLIO"
+
 type btAlgoRunner struct {
 	algoName            string
 	symbol              string
 	ptr                 reflect.Value
 	ainterface          interface{}
 	strategy            AlgoStrategy
+	observer            OrderObserver
+	fillModel           FillModel
+	latencyModel        LatencyModel
+	costModel           CostModel
+	rand                *rand.Rand
+	closing             bool
+	queuePosition       bool
+	volumeTraded        int
 	book                Book
 	watch               []string
 	enable              bool
 	lastTick            kstreamdb.TickData
-	queueTick           []kstreamdb.TickData
 	utcLastPeriodicCall int64
 	orders              []orderEntry
 }
@@ -26,25 +38,35 @@ func (a *btAlgoRunner) ID() string {
 	return a.algoName + "::" + a.symbol
 }
 
-func (a *btAlgoRunner) queue(t kstreamdb.TickData) {
+// tickCount returns the ticks of the day for the subscribed symbols
+func (a *btAlgoRunner) tickCount(ticks *btTickManager) int {
+	count := 0
 	if a.enable {
-		a.queueTick = append(a.queueTick, t)
+		for _, symbol := range ticks.expand(a.book.subscriptions) {
+			count += ticks.count(symbol)
+		}
 	}
+	return count
 }
 
-func (a *btAlgoRunner) resetQueue() {
-	a.queueTick = make([]kstreamdb.TickData, 0, len(a.watch)*24000)
-}
-
-func (a *btAlgoRunner) run() {
+func (a *btAlgoRunner) run(ticks *btTickManager) {
 	if a.enable {
+		a.book.resetSquareOff()
 		a.strategy.OnDayStart(&a.book)
-		for _, t := range a.queueTick {
+		a.dispatchOrderUpdates()
+		cursor := newTickCursor(ticks, a.book.subscriptions)
+		a.book.subscriptionsChanged = false
+		for t, ok := cursor.next(); ok; t, ok = cursor.next() {
 			a.checkClock(t.Timestamp)
 			a.handleTick(t)
+			if a.book.subscriptionsChanged {
+				cursor.subscribe(a.book.subscriptions)
+				a.book.subscriptionsChanged = false
+			}
 		}
 		a.strategy.OnDayEnd(&a.book)
-		a.resetQueue()
+		a.book.expireOrders(true)
+		a.dispatchOrderUpdates()
 		//fmt.Printf("P/L %9.2f | Trades %3d | %s\n", a.book.Cash-a.book.CashAllocated, a.book.OrderCount, a.ID())
 	}
 }
@@ -52,7 +74,24 @@ func (a *btAlgoRunner) run() {
 func (a *btAlgoRunner) exit() {
 	if a.enable {
 		a.strategy.OnClose(&a.book)
-		a.handleBook()
+		// orders placed on close are filled against the last tick of their
+		// symbol, regardless of the latency
+		a.closing = true
+		symbols := make([]string, 0)
+		for _, o := range a.book.working {
+			symbols = append(symbols, o.Symbol)
+		}
+		sort.Strings(symbols)
+		for i, symbol := range symbols {
+			if i > 0 && symbols[i-1] == symbol {
+				continue
+			}
+			if t, ok := a.book.quotes[symbol]; ok {
+				a.lastTick = t
+				a.handleBook()
+			}
+		}
+		a.dispatchOrderUpdates()
 	}
 }
 
@@ -60,97 +99,211 @@ func (a *btAlgoRunner) checkClock(t time.Time) {
 	utcNow := t.Unix()
 	if a.utcLastPeriodicCall < utcNow {
 		a.utcLastPeriodicCall = utcNow
+		a.book.setClock(t)
+		a.book.squareOff(t)
 		a.strategy.OnPeriodic(time.Unix(utcNow, 0), &a.book)
+		a.dispatchOrderUpdates()
 	}
 }
 
-func (a *btAlgoRunner) handleBook() {
-	if a.book.IsOrderWaiting() {
-		if a.book.IsMarketOrder {
-			if a.book.PendingOrderQuantity > 0 {
-				buyPrice := a.lastTick.Ask[0].Price
-				if buyPrice <= 0 {
-					buyPrice = a.lastTick.LastPrice
-				}
-				cost := buyPrice * float32(a.book.PendingOrderQuantity)
-				a.book.Cash -= float64(cost)
-				a.book.Position += a.book.PendingOrderQuantity
-				// add trade trade ledger
-				a.orders = append(a.orders, orderEntry{
-					algoName: a.algoName,
-					at:       a.lastTick.Timestamp,
-					symbol:   a.symbol,
-					qty:      a.book.PendingOrderQuantity,
-					price:    float64(buyPrice),
-				})
-
-				a.book.PendingOrderQuantity = 0
-				a.book.OrderCount++
-			} else if a.book.PendingOrderQuantity < 0 {
-				sellPrice := a.lastTick.Bid[0].Price
-				if sellPrice <= 0 {
-					sellPrice = a.lastTick.LastPrice
-				}
-
-				cost := sellPrice * float32(a.book.PendingOrderQuantity)
-				a.book.Cash -= float64(cost)
-				a.book.Position += a.book.PendingOrderQuantity
-				// add trade trade ledger
-				a.orders = append(a.orders, orderEntry{
-					algoName: a.algoName,
-					at:       a.lastTick.Timestamp,
-					symbol:   a.symbol,
-					qty:      a.book.PendingOrderQuantity,
-					price:    float64(sellPrice),
-				})
-
-				a.book.PendingOrderQuantity = 0
-				a.book.OrderCount++
-			}
-		} else {
-			if a.book.PendingOrderQuantity > 0 {
-				if a.lastTick.LastPrice <= float32(a.book.PendingOrderPrice) {
-					cost := a.book.PendingOrderPrice * float64(a.book.PendingOrderQuantity)
-					a.book.Cash -= float64(cost)
-					a.book.Position += a.book.PendingOrderQuantity
-					// add trade trade ledger
-					a.orders = append(a.orders, orderEntry{
-						algoName: a.algoName,
-						at:       a.lastTick.Timestamp,
-						symbol:   a.symbol,
-						qty:      a.book.PendingOrderQuantity,
-						price:    float64(a.book.PendingOrderPrice),
-					})
-					a.book.PendingOrderQuantity = 0
-					a.book.OrderCount++
-				}
-			} else if a.book.PendingOrderQuantity < 0 {
-				if a.lastTick.LastPrice >= float32(a.book.PendingOrderPrice) {
-					cost := a.book.PendingOrderPrice * float64(a.book.PendingOrderQuantity)
-					a.book.Cash -= float64(cost)
-					a.book.Position += a.book.PendingOrderQuantity
-					// add trade trade ledger
-					a.orders = append(a.orders, orderEntry{
-						algoName: a.algoName,
-						at:       a.lastTick.Timestamp,
-						symbol:   a.symbol,
-						qty:      a.book.PendingOrderQuantity,
-						price:    float64(a.book.PendingOrderPrice),
-					})
-					a.book.PendingOrderQuantity = 0
-					a.book.OrderCount++
-				}
+// dispatchOrderUpdates passes the queued order updates to the strategy,
+// updates raised from within the callback are dispatched in turn
+func (a *btAlgoRunner) dispatchOrderUpdates() {
+	for updates := a.book.popUpdates(); len(updates) > 0; updates = a.book.popUpdates() {
+		if a.book.portfolio != nil {
+			a.book.portfolio.update(&a.book)
+		}
+		for _, u := range updates {
+			a.record(u)
+			if a.observer != nil {
+				a.observer.OnOrderUpdate(u, &a.book)
 			}
 		}
 	}
 }
 
+// record the order update in the order ledger
+func (a *btAlgoRunner) record(u OrderUpdate) {
+	e := orderEntry{
+		algoName:   a.algoName,
+		instanceID: a.ID(),
+		symbol:     u.Order.Symbol,
+		orderID:    u.Order.ID,
+		event:      u.Event,
+		placedAt:   u.Order.PlacedAt,
+		at:         u.At,
+	}
+	switch u.Event {
+	case OrderAccepted:
+		return
+	case OrderFilled, OrderPartiallyFilled:
+		e.qty = u.Order.signed(u.FillQuantity)
+		e.price = u.FillPrice
+		e.charges = u.Charges
+	case OrderCancelled:
+		e.qty = u.Order.signed(u.Order.PendingQuantity())
+		e.price = u.Order.Price
+	default:
+		e.qty = u.Order.signed(u.Order.Quantity)
+		e.price = u.Order.Price
+	}
+	a.orders = append(a.orders, e)
+}
+
+// handleBook matches the working orders of the symbol of the last tick
+func (a *btAlgoRunner) handleBook() {
+	symbol := a.lastTick.TradingSymbol
+	a.book.markToMarket()
+	a.book.expireOrders(false)
+	a.book.trailBrackets(symbol, float64(a.lastTick.LastPrice))
+	for _, o := range a.book.working {
+		if o.IsOpen() && o.Symbol == symbol {
+			a.handleOrder(o)
+		}
+	}
+	a.book.purgeWorkingOrders()
+}
+
+func (a *btAlgoRunner) handleOrder(o *Order) {
+	if !a.isEligible(o) {
+		return
+	}
+	justTriggered := false
+	if o.IsStop() {
+		if !a.isTriggered(o) {
+			return
+		}
+		o.Triggered = true
+		o.UpdatedAt = a.lastTick.Timestamp
+		justTriggered = true
+	}
+
+	switch o.TimeInForce {
+	case TIFIOC:
+		a.matchImmediate(o, justTriggered)
+		if o.IsOpen() {
+			a.book.cancelOrder(o)
+		}
+	case TIFFOK:
+		if a.isFillableInFull(o) {
+			a.matchImmediate(o, justTriggered)
+		}
+		if o.IsOpen() {
+			a.book.cancelOrder(o)
+		}
+	default:
+		a.matchOrder(o, justTriggered)
+	}
+}
+
+// isEligible checks if the order has reached the exchange, after the latency
+func (a *btAlgoRunner) isEligible(o *Order) bool {
+	if o.EligibleAt.IsZero() {
+		o.EligibleAt = o.PlacedAt
+		if a.latencyModel != nil && o.ParentID == 0 {
+			// bracket legs are held at the exchange, no latency
+			o.EligibleAt = o.PlacedAt.Add(a.latencyModel.Latency(*o, a.rand))
+		}
+	}
+	return a.closing || !a.lastTick.Timestamp.Before(o.EligibleAt)
+}
+
+// isFillableInFull checks if the depth can take the pending quantity of the
+// order, ticks without depth are assumed to take any quantity
+func (a *btAlgoRunner) isFillableInFull(o *Order) bool {
+	if !hasDepth(o.Side, a.lastTick) {
+		return o.isMarketable(float64(a.lastTick.LastPrice))
+	}
+	qty, _ := walkDepth(o, a.lastTick)
+	return qty >= o.PendingQuantity()
+}
+
+// matchImmediate fills the IOC and FOK orders, limit orders take the depth
+// up to the limit price at the depth prices, without joining the queue
+func (a *btAlgoRunner) matchImmediate(o *Order, justTriggered bool) {
+	if o.isLimit() && hasDepth(o.Side, a.lastTick) {
+		if qty, price := walkDepth(o, a.lastTick); qty > 0 {
+			a.fillOrder(o, qty, price)
+		}
+		return
+	}
+	a.matchOrder(o, justTriggered)
+}
+
+// matchOrder fills the active order if the tick allows
+func (a *btAlgoRunner) matchOrder(o *Order, justTriggered bool) {
+	if !o.isLimit() || justTriggered {
+		// market orders, and stop-limit orders on trigger are filled by the fill model
+		if qty, price := a.fillModel.Fill(*o, a.lastTick); qty > 0 {
+			a.fillOrder(o, qty, price)
+			return
+		}
+	}
+	if !o.isLimit() {
+		return
+	}
+	if a.queuePosition && !a.closing {
+		a.matchQueued(o)
+	} else if qty, price := walkDepth(o, a.lastTick); qty > 0 {
+		// limit orders crossing the depth take the opposite side at its prices
+		a.fillOrder(o, qty, price)
+	} else if o.isMarketable(float64(a.lastTick.LastPrice)) {
+		a.fillOrder(o, o.PendingQuantity(), o.Price)
+	}
+}
+
+// isTriggered checks the stop order trigger against LTP, as the exchange does
+func (a *btAlgoRunner) isTriggered(o *Order) bool {
+	ltp := float64(a.lastTick.LastPrice)
+	if o.Side == SideBuy {
+		return ltp >= o.TriggerPrice
+	}
+	return ltp <= o.TriggerPrice
+}
+
+// fillOrder fills the quantity of the order at the price, the order keeps
+// working for the remaining quantity
+func (a *btAlgoRunner) fillOrder(o *Order, filled int, price float64) {
+	prevFilled, prevAvg := o.FilledQuantity, o.AvgFillPrice
+	o.AvgFillPrice = (o.AvgFillPrice*float64(o.FilledQuantity) + price*float64(filled)) / float64(o.FilledQuantity+filled)
+	o.FilledQuantity += filled
+	charges := 0.0
+	if a.costModel != nil {
+		// brokerage is per order, the fill is charged what it adds to the order
+		charges = a.costModel.Charges(o.Symbol, o.signed(o.FilledQuantity), o.AvgFillPrice)
+		if prevFilled > 0 {
+			charges -= a.costModel.Charges(o.Symbol, o.signed(prevFilled), prevAvg)
+		}
+	}
+	a.book.applyFill(o.Symbol, o.signed(filled), price, charges)
+	o.UpdatedAt = a.lastTick.Timestamp
+	if o.PendingQuantity() > 0 {
+		a.book.notifyFill(OrderPartiallyFilled, o, filled, price, charges)
+	} else {
+		o.Status = OrderStatusFilled
+		a.book.OrderCount++
+		a.book.notifyFill(OrderFilled, o, filled, price, charges)
+	}
+	a.book.settleOCO(o)
+	a.book.attachBracketLegs(o, filled, price)
+}
+
 func (a *btAlgoRunner) handleTick(t kstreamdb.TickData) {
-	if (a.symbol == t.TradingSymbol) && t.IsTradable {
+	a.book.setClock(t.Timestamp)
+	if t.IsTradable {
+		// orders are matched against the tick of their own symbol
+		prev := a.book.quoteOf(t.TradingSymbol)
+		a.volumeTraded = 0
+		if t.VolumeTraded > prev.VolumeTraded && prev.VolumeTraded > 0 {
+			a.volumeTraded = int(t.VolumeTraded - prev.VolumeTraded)
+		}
 		a.lastTick = t
+		a.book.updateQuote(t)
 		a.handleBook()
+		a.dispatchOrderUpdates()
 	}
 	a.strategy.OnTick(t, &a.book)
+	a.dispatchOrderUpdates()
 }
 
 func (a *btAlgoRunner) popOrders() []orderEntry {
@@ -159,22 +312,61 @@ func (a *btAlgoRunner) popOrders() []orderEntry {
 	return orders
 }
 
-func newAlgoInstance(algoType reflect.Type, symbol string) *btAlgoRunner {
+// portfolioAdapter runs a PortfolioStrategy as an AlgoStrategy
+type portfolioAdapter struct {
+	PortfolioStrategy
+}
+
+// Setup method
+func (p portfolioAdapter) Setup(symbol string, b *Book) []string {
+	return p.PortfolioStrategy.Setup(b)
+}
+
+func newAlgoInstance(spec algoSpec, symbol string, config btConfig) *btAlgoRunner {
+	a := newRunner(spec, symbol, config)
+	a.strategy = a.ptr.Interface().(AlgoStrategy)
+	a.book.symbol = symbol
+	a.setup(config)
+	return a
+}
+
+// newPortfolioInstance instantiates the portfolio strategy, the book has no
+// instance symbol and orders name their symbol
+func newPortfolioInstance(spec algoSpec, config btConfig) *btAlgoRunner {
+	a := newRunner(spec, portfolioInstanceSymbol, config)
+	a.strategy = portfolioAdapter{a.ptr.Interface().(PortfolioStrategy)}
+	a.setup(config)
+	return a
+}
+
+func newRunner(spec algoSpec, symbol string, config btConfig) *btAlgoRunner {
 	a := new(btAlgoRunner)
-	a.algoName = algoType.Name()
+	a.fillModel = config.fillModel
+	if a.fillModel == nil {
+		a.fillModel = DepthFill{}
+	}
+	a.algoName = spec.name
 	a.symbol = symbol
-	a.book = Book{}
-	a.ptr = reflect.New(algoType)
-	a.strategy = a.ptr.Interface().(AlgoStrategy)
-	a.watch = a.strategy.Setup(symbol, &a.book)
-	a.enable = len(a.watch) > 0
-	a.utcLastPeriodicCall = 0
+	a.latencyModel = config.latencyModel
+	a.queuePosition = config.queuePosition
+	a.costModel = config.costModel
+	a.rand = newInstanceRand(config.seed, a.ID())
+	a.book = Book{CircuitLimit: 20}
+	a.ptr = reflect.ValueOf(spec.newInstance())
+	a.observer, _ = a.ptr.Interface().(OrderObserver)
+	return a
+}
 
-	if a.enable {
-		// prealloc queue
-		a.resetQueue()
+func (a *btAlgoRunner) setup(config btConfig) {
+	a.watch = a.strategy.Setup(a.book.symbol, &a.book)
+	a.enable = len(a.watch) > 0
+	for _, w := range a.watch {
+		a.book.Subscribe(w)
+	}
+	if a.enable && config.portfolio != nil {
+		config.portfolio.attach(&a.book, a.ID(), a.algoName)
 	}
+	a.utcLastPeriodicCall = 0
 	a.orders = make([]orderEntry, 0)
 	//fmt.Printf("%+v %+v %+v \n", a.ptr, reflect.TypeOf(a.ptr), a.ptr.Interface().(AlgoStrategy))
-	return a
 }
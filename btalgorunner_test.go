@@ -0,0 +1,356 @@
+package malgova
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/sivamgr/kstreamdb"
+)
+
+const testSymbol = "SBIN"
+
+var testStart = time.Date(2020, 7, 1, 9, 15, 0, 0, time.Local)
+
+// scriptAlgo runs the script of the tick number, after the book is matched
+// against the tick
+type scriptAlgo struct {
+	setup   func(b *Book)
+	script  map[int]func(b *Book)
+	watch   []string
+	n       int
+	seen    []string
+	updates []OrderUpdate
+}
+
+func (s *scriptAlgo) Setup(symbol string, b *Book) []string {
+	b.AllocateCash(1000000)
+	if s.setup != nil {
+		s.setup(b)
+	}
+	return append([]string{symbol}, s.watch...)
+}
+
+func (s *scriptAlgo) OnTick(t kstreamdb.TickData, b *Book) {
+	s.seen = append(s.seen, t.TradingSymbol)
+	if f, ok := s.script[s.n]; ok {
+		f(b)
+	}
+	s.n++
+}
+
+func (s *scriptAlgo) OnOrderUpdate(u OrderUpdate, b *Book) {
+	s.updates = append(s.updates, u)
+}
+
+func (s *scriptAlgo) OnDayStart(b *Book)              {}
+func (s *scriptAlgo) OnDayEnd(b *Book)                {}
+func (s *scriptAlgo) OnPeriodic(t time.Time, b *Book) {}
+func (s *scriptAlgo) OnClose(b *Book)                 {}
+
+// depth returns the depth levels of the price and quantity pairs
+func depth(levels ...float32) [5]kstreamdb.DepthItem {
+	var d [5]kstreamdb.DepthItem
+	for i := 0; i+1 < len(levels) && i/2 < len(d); i += 2 {
+		d[i/2] = kstreamdb.DepthItem{Price: levels[i], Quantity: uint32(levels[i+1])}
+	}
+	return d
+}
+
+func testTick(sec int, ltp float32, bid [5]kstreamdb.DepthItem, ask [5]kstreamdb.DepthItem) kstreamdb.TickData {
+	return kstreamdb.TickData{
+		TradingSymbol: testSymbol,
+		IsTradable:    true,
+		Timestamp:     testStart.Add(time.Duration(sec) * time.Second),
+		LastPrice:     ltp,
+		LastDayClose:  100,
+		Bid:           bid,
+		Ask:           ask,
+	}
+}
+
+// quietTick is the first tick of the cases, the orders of the script are
+// placed on it
+func quietTick() kstreamdb.TickData {
+	return testTick(0, 100, depth(99.5, 1000), depth(100.5, 1000))
+}
+
+// indexTick is a non-tradable tick of the NIFTY 50 index
+func indexTick(sec int, ltp float32) kstreamdb.TickData {
+	return kstreamdb.TickData{
+		TradingSymbol: "NIFTY 50",
+		Timestamp:     testStart.Add(time.Duration(sec) * time.Second),
+		LastPrice:     ltp,
+	}
+}
+
+// traded sets the volume traded of the day on the tick
+func traded(t kstreamdb.TickData, volume uint32) kstreamdb.TickData {
+	t.VolumeTraded = volume
+	return t
+}
+
+// of sets the symbol of the tick
+func of(symbol string, t kstreamdb.TickData) kstreamdb.TickData {
+	t.TradingSymbol = symbol
+	return t
+}
+
+// tickCase runs the script over the ticks and checks the book
+type tickCase struct {
+	name         string
+	symbol       string
+	config       btConfig
+	setup        func(b *Book)
+	watch        []string
+	script       map[int]func(b *Book)
+	ticks        []kstreamdb.TickData
+	closeSession bool
+	check        func(t *testing.T, b *Book)
+	checkUpdates func(t *testing.T, updates []OrderUpdate)
+}
+
+// run the ticks through a new instance of the script algo, ticks of the
+// test symbol are of the instance symbol
+func (c tickCase) run() *btAlgoRunner {
+	symbol := c.symbol
+	if symbol == "" {
+		symbol = testSymbol
+	}
+	algo := &scriptAlgo{setup: c.setup, script: c.script, watch: c.watch}
+	spec := algoSpec{name: "script", newInstance: func() interface{} { return algo }}
+	a := newAlgoInstance(spec, symbol, c.config)
+	for _, t := range c.ticks {
+		if t.TradingSymbol == testSymbol {
+			t.TradingSymbol = symbol
+		}
+		a.checkClock(t.Timestamp)
+		a.handleTick(t)
+	}
+	if c.closeSession {
+		a.book.expireOrders(true)
+		a.dispatchOrderUpdates()
+	}
+	return a
+}
+
+func runTickCases(t *testing.T, tests []tickCase) {
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := tt.run()
+			if tt.check != nil {
+				tt.check(t, &a.book)
+			}
+			if tt.checkUpdates != nil {
+				tt.checkUpdates(t, a.strategy.(*scriptAlgo).updates)
+			}
+		})
+	}
+}
+
+func order(t *testing.T, b *Book, id int) Order {
+	t.Helper()
+	o, ok := b.Order(id)
+	if !ok {
+		t.Fatalf("order %d not found", id)
+	}
+	return o
+}
+
+func wantOrder(t *testing.T, b *Book, id int, status OrderStatus, filled int, avgPrice float64) {
+	t.Helper()
+	o := order(t, b, id)
+	if o.Status != status || o.FilledQuantity != filled || math.Abs(o.AvgFillPrice-avgPrice) > 1e-6 {
+		t.Errorf("order %d: got %s filled %d at %.4f, want %s filled %d at %.4f (%s)",
+			id, o.Status, o.FilledQuantity, o.AvgFillPrice, status, filled, avgPrice, o.RejectReason)
+	}
+}
+
+func wantFloat(t *testing.T, name string, got float64, want float64) {
+	t.Helper()
+	if math.Abs(got-want) > 1e-6 {
+		t.Errorf("%s %.6f, want %.6f", name, got, want)
+	}
+}
+
+func TestStopOrders(t *testing.T) {
+	runTickCases(t, []tickCase{
+		{
+			name:   "stop-market triggers and walks the depth",
+			script: map[int]func(b *Book){0: func(b *Book) { b.SellStop(50, 98) }},
+			ticks: []kstreamdb.TickData{quietTick(),
+				testTick(1, 99, depth(98.5, 100), depth(99.5, 100)),
+				testTick(2, 98, depth(97.5, 20, 97, 100), depth(98.5, 100))},
+			check: func(t *testing.T, b *Book) {
+				wantOrder(t, b, 1, OrderStatusFilled, 50, (97.5*20+97*30)/50.0)
+			},
+		},
+		{
+			name:   "stop waits for the trigger",
+			script: map[int]func(b *Book){0: func(b *Book) { b.BuyStop(10, 102) }},
+			ticks:  []kstreamdb.TickData{quietTick(), testTick(1, 101.5, depth(101, 100), depth(101.5, 100))},
+			check: func(t *testing.T, b *Book) {
+				wantOrder(t, b, 1, OrderStatusOpen, 0, 0)
+				if o := order(t, b, 1); o.Triggered {
+					t.Error("triggered below the trigger price")
+				}
+			},
+		},
+		{
+			name:   "stop-limit respects the limit price",
+			script: map[int]func(b *Book){0: func(b *Book) { b.BuyStopLimit(100, 102, 102.5) }},
+			ticks: []kstreamdb.TickData{quietTick(),
+				testTick(1, 102, depth(101.5, 100), depth(102, 40, 102.5, 40, 103, 100))},
+			check: func(t *testing.T, b *Book) {
+				wantOrder(t, b, 1, OrderStatusOpen, 80, (102*40+102.5*40)/80.0)
+			},
+		},
+	})
+}
+
+func TestTimeInForce(t *testing.T) {
+	runTickCases(t, []tickCase{
+		{
+			name: "IOC limit fills at the depth price",
+			script: map[int]func(b *Book){0: func(b *Book) {
+				b.SetTimeInForce(b.BuyLimit(50, 101), TIFIOC)
+			}},
+			ticks: []kstreamdb.TickData{quietTick(), testTick(1, 101.5, depth(99, 100), depth(100, 1000))},
+			check: func(t *testing.T, b *Book) {
+				wantOrder(t, b, 1, OrderStatusFilled, 50, 100)
+			},
+		},
+		{
+			name: "IOC cancels the quantity beyond the limit",
+			script: map[int]func(b *Book){0: func(b *Book) {
+				b.SetTimeInForce(b.BuyLimit(150, 101), TIFIOC)
+			}},
+			ticks: []kstreamdb.TickData{quietTick(), testTick(1, 100, depth(99, 100), depth(100, 100, 102, 100))},
+			check: func(t *testing.T, b *Book) {
+				wantOrder(t, b, 1, OrderStatusCancelled, 100, 100)
+			},
+		},
+		{
+			name: "FOK cancels when the depth is short",
+			script: map[int]func(b *Book){0: func(b *Book) {
+				b.SetTimeInForce(b.Buy(300), TIFFOK)
+			}},
+			ticks: []kstreamdb.TickData{quietTick(), testTick(1, 100, depth(99, 100), depth(100, 100, 101, 100))},
+			check: func(t *testing.T, b *Book) {
+				wantOrder(t, b, 1, OrderStatusCancelled, 0, 0)
+			},
+		},
+		{
+			name: "FOK fills when the depth is enough",
+			script: map[int]func(b *Book){0: func(b *Book) {
+				b.SetTimeInForce(b.Buy(200), TIFFOK)
+			}},
+			ticks: []kstreamdb.TickData{quietTick(), testTick(1, 100, depth(99, 100), depth(100, 100, 101, 100))},
+			check: func(t *testing.T, b *Book) {
+				wantOrder(t, b, 1, OrderStatusFilled, 200, 100.5)
+			},
+		},
+		{
+			name: "GTT expires at the expiry time",
+			script: map[int]func(b *Book){0: func(b *Book) {
+				b.SetGoodTill(b.BuyLimit(10, 90), testStart.Add(2*time.Second))
+			}},
+			ticks: []kstreamdb.TickData{quietTick(), testTick(1, 100, depth(), depth()), testTick(2, 100, depth(), depth())},
+			check: func(t *testing.T, b *Book) {
+				wantOrder(t, b, 1, OrderStatusExpired, 0, 0)
+			},
+		},
+		{
+			name: "GTT works until the expiry time",
+			script: map[int]func(b *Book){0: func(b *Book) {
+				b.SetGoodTill(b.BuyLimit(10, 90), testStart.Add(2*time.Second))
+			}},
+			ticks:        []kstreamdb.TickData{quietTick(), testTick(1, 100, depth(), depth())},
+			closeSession: true,
+			check: func(t *testing.T, b *Book) {
+				wantOrder(t, b, 1, OrderStatusOpen, 0, 0)
+			},
+		},
+		{
+			name: "GTT without a future expiry is not set",
+			script: map[int]func(b *Book){0: func(b *Book) {
+				id := b.BuyLimit(10, 90)
+				if b.SetTimeInForce(id, TIFGTT) != ErrInvalidExpiry || b.SetGoodTill(id, testStart) != ErrInvalidExpiry {
+					b.Cancel(id)
+				}
+			}},
+			ticks: []kstreamdb.TickData{quietTick(), testTick(1, 100, depth(), depth())},
+			check: func(t *testing.T, b *Book) {
+				if o := order(t, b, 1); !o.IsOpen() || o.TimeInForce != TIFDay {
+					t.Errorf("%s %s, want open DAY", o.Status, o.TimeInForce)
+				}
+			},
+		},
+		{
+			name:         "DAY expires at the session close",
+			script:       map[int]func(b *Book){0: func(b *Book) { b.BuyLimit(10, 90) }},
+			ticks:        []kstreamdb.TickData{quietTick(), testTick(1, 100, depth(), depth())},
+			closeSession: true,
+			check: func(t *testing.T, b *Book) {
+				wantOrder(t, b, 1, OrderStatusExpired, 0, 0)
+			},
+		},
+		{
+			name: "GTC survives the session close",
+			script: map[int]func(b *Book){0: func(b *Book) {
+				b.SetTimeInForce(b.BuyLimit(10, 90), TIFGTC)
+			}},
+			ticks:        []kstreamdb.TickData{quietTick(), testTick(1, 100, depth(), depth())},
+			closeSession: true,
+			check: func(t *testing.T, b *Book) {
+				wantOrder(t, b, 1, OrderStatusOpen, 0, 0)
+			},
+		},
+	})
+}
+
+// portfolioScript runs the script as a portfolio strategy over all the symbols
+type portfolioScript struct {
+	scriptAlgo
+}
+
+func (p *portfolioScript) Setup(b *Book) []string {
+	b.AllocateCash(1000000)
+	return []string{AllSymbols}
+}
+
+func TestPortfolioStrategy(t *testing.T) {
+	mgr := feedOf("SBIN INFY SBIN INFY SBIN")
+	algo := &portfolioScript{scriptAlgo{script: map[int]func(b *Book){
+		0: func(b *Book) { b.Buy(10) },
+		1: func(b *Book) { b.BuySymbol("SBIN", 10); b.SellSymbol("INFY", 20) },
+		3: func(b *Book) { b.SellSymbol("SBIN", 10) },
+	}}}
+	a := newPortfolioInstance(algoSpec{name: "rotation", newInstance: func() interface{} { return algo }}, btConfig{})
+	a.run(mgr)
+	b := &a.book
+	if o := order(t, b, 1); o.RejectReason != ErrNoSymbol.Error() {
+		t.Errorf("order without a symbol: %s %q", o.Status, o.RejectReason)
+	}
+	wantOrder(t, b, 2, OrderStatusFilled, 10, 100.5)
+	wantOrder(t, b, 3, OrderStatusFilled, 20, 99.5)
+	wantOrder(t, b, 4, OrderStatusFilled, 10, 99.5)
+	if b.PositionOf("SBIN") != 0 || b.PositionOf("INFY") != -20 {
+		t.Errorf("positions SBIN %d INFY %d", b.PositionOf("SBIN"), b.PositionOf("INFY"))
+	}
+	if len(algo.seen) != 5 {
+		t.Errorf("%d ticks seen, want every tick of the feed", len(algo.seen))
+	}
+
+	scores := calculateAlgoScores(a.popOrders())
+	bySymbol := make(map[string]AlgoScore)
+	for _, s := range scores {
+		bySymbol[s.Symbol] = s
+	}
+	if s := bySymbol["SBIN"]; s.AlgoName != "rotation" || s.TradesCount != 1 || s.OrdersRejected != 0 {
+		t.Errorf("SBIN score %+v", s)
+	}
+	if s, ok := bySymbol["INFY"]; !ok || s.TradesCount != 0 {
+		t.Errorf("INFY score %+v", s)
+	}
+}
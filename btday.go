@@ -2,7 +2,6 @@ package malgova
 
 import (
 	"log"
-	"reflect"
 	"sync"
 	"time"
 
@@ -11,40 +10,79 @@ import (
 
 // btDayRunner struct
 type btDayRunner struct {
-	algos               []reflect.Type
-	tickManager         map[string]*btTickManager
+	algos               []algoSpec
+	config              btConfig
 	algoRunner          map[string]*btAlgoRunner
 	flagSymbolAlgoSetup map[string]bool
 	orders              []orderEntry
+	prevTicks           []kstreamdb.TickData
 }
 
 func (bt *btDayRunner) instantiateAllAlgosForSymbol(symbol string) {
 	//spawn algos for symbol
 
 	for _, a := range bt.algos {
-		pAlgo := newAlgoInstance(a, symbol)
-		algoID := pAlgo.ID()
-		bt.algoRunner[algoID] = pAlgo
-		for _, w := range pAlgo.watch {
-			if _, ok := bt.tickManager[w]; !ok {
-				bt.tickManager[symbol] = new(btTickManager)
+		if _, ok := bt.config.universe[a.name]; ok {
+			continue
+		}
+		pAlgo := newAlgoInstance(a, symbol, bt.config)
+		bt.algoRunner[pAlgo.ID()] = pAlgo
+	}
+}
+
+// instantiateUniverse spawns the algos for the symbols of their universe of
+// the day. Instances of the symbols dropped from the universe are disabled
+// once flat, until then they may only place orders reducing the position.
+func (bt *btDayRunner) instantiateUniverse(dt time.Time, tradable map[string]bool) {
+	for _, a := range bt.algos {
+		u, ok := bt.config.universe[a.name]
+		if !ok {
+			continue
+		}
+		selected := make(map[string]bool)
+		for _, symbol := range u.Symbols(dt, bt.prevTicks) {
+			if !tradable[symbol] {
+				continue
+			}
+			selected[symbol] = true
+			if algo, ok := bt.algoRunner[a.name+"::"+symbol]; ok {
+				algo.enable = len(algo.watch) > 0
+				algo.book.exitOnly = false
+			} else {
+				pAlgo := newAlgoInstance(a, symbol, bt.config)
+				bt.algoRunner[pAlgo.ID()] = pAlgo
+			}
+		}
+		for _, algo := range bt.algoRunner {
+			if algo.algoName != a.name || selected[algo.symbol] || !algo.enable {
+				continue
+			}
+			if algo.book.IsBookClean() {
+				algo.enable = false
+			} else {
+				algo.book.exitOnly = true
 			}
-			bt.tickManager[symbol].addObserver(algoID)
 		}
 	}
 }
 
 // worker for concurrent algo execution
-func algoRunWorker(wg *sync.WaitGroup, algo *btAlgoRunner, bt *btDayRunner) {
+func algoRunWorker(wg *sync.WaitGroup, algo *btAlgoRunner, ticks *btTickManager) {
 	defer wg.Done()
-	algo.run()
+	algo.run(ticks)
 }
 
-func (bt *btDayRunner) setup(algos []reflect.Type) {
+func (bt *btDayRunner) setup(algos []algoSpec, portfolioAlgos []algoSpec, config btConfig) {
 	bt.algos = algos
-	bt.tickManager = make(map[string]*btTickManager)
+	bt.config = config
 	bt.algoRunner = make(map[string]*btAlgoRunner)
 	bt.flagSymbolAlgoSetup = make(map[string]bool)
+	bt.prevTicks = nil
+	// portfolio strategies are instantiated once for the run
+	for _, a := range portfolioAlgos {
+		pAlgo := newPortfolioInstance(a, bt.config)
+		bt.algoRunner[pAlgo.ID()] = pAlgo
+	}
 	// reset orders
 	bt.orders = make([]orderEntry, 0)
 }
@@ -65,29 +103,30 @@ func (bt *btDayRunner) popOrders() []orderEntry {
 
 //run day data against algos
 func (bt *btDayRunner) run(dt time.Time, ticks []kstreamdb.TickData) {
-
+	tradable := make(map[string]bool)
 	for _, t := range ticks {
 		// instantiate algo runners if not instantiated already
 		if t.IsTradable {
+			tradable[t.TradingSymbol] = true
 			if _, ok := bt.flagSymbolAlgoSetup[t.TradingSymbol]; !ok {
 				bt.flagSymbolAlgoSetup[t.TradingSymbol] = true
 				bt.instantiateAllAlgosForSymbol(t.TradingSymbol)
 			}
 		}
-
-		// pass data to algos subscribed to this symbol
-		if tickMgr, ok := bt.tickManager[t.TradingSymbol]; ok {
-			for _, algoid := range tickMgr.observerAlgoIDs {
-				pAlgo := bt.algoRunner[algoid]
-				// queue tick for handling
-				pAlgo.queue(t)
-			}
-		}
+	}
+	bt.instantiateUniverse(dt, tradable)
+	if needsPrevDay(bt.config.universe) {
+		// the last tick of each symbol is kept for the universe selection of
+		// the next day, not the ticks of the whole day
+		bt.prevTicks = lastTicks(ticks)
 	}
 
+	// algos walk the ticks of the symbols subscribed, including the
+	// non-tradable index ticks and the symbols subscribed during the day
+	tickMgr := newTickManager(ticks)
 	inQueueCount := 0
 	for _, algo := range bt.algoRunner {
-		inQueueCount += len(algo.queueTick)
+		inQueueCount += algo.tickCount(tickMgr)
 	}
 	log.Printf("[%s] %d ticks in Queue", dt.Format("2006/01/02"), inQueueCount)
 
@@ -95,8 +134,17 @@ func (bt *btDayRunner) run(dt time.Time, ticks []kstreamdb.TickData) {
 	// run the runners
 	for _, algo := range bt.algoRunner {
 		wg.Add(1)
-		go algoRunWorker(&wg, algo, bt)
+		go algoRunWorker(&wg, algo, tickMgr)
 	}
 
 	wg.Wait()
+
+	if p := bt.config.portfolio; p != nil {
+		for _, algo := range bt.algoRunner {
+			if algo.enable {
+				p.update(&algo.book)
+			}
+		}
+		p.recordEquity(dt)
+	}
 }
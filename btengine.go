@@ -1,6 +1,7 @@
 package malgova
 
 import (
+	"fmt"
 	"log"
 	"reflect"
 	"sync"
@@ -11,73 +12,190 @@ import (
 
 // BacktestEngine struct
 type BacktestEngine struct {
-	algos  []reflect.Type
-	orders []orderEntry
-	scores []AlgoScore
+	algos          []algoSpec
+	portfolioAlgos []algoSpec
+	config         btConfig
+	orders         []orderEntry
+	scores         []AlgoScore
 }
 
-// RegisterAlgo BacktestEngine
-func (bt *BacktestEngine) RegisterAlgo(a interface{}) {
-	if bt.algos == nil {
-		bt.algos = make([]reflect.Type, 0)
+// algoSpec is a registered algo, the name identifies the parameter set in
+// the instance ids and the scores
+type algoSpec struct {
+	name        string
+	newInstance func() interface{}
+}
+
+// copyOf returns the factory for copies of the registered value, the copies
+// are shallow, maps, slices and pointers are shared by the instances
+func copyOf(a interface{}) (string, func() interface{}) {
+	v := reflect.ValueOf(a)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	return v.Type().Name(), func() interface{} {
+		ptr := reflect.New(v.Type())
+		ptr.Elem().Set(v)
+		return ptr.Interface()
+	}
+}
+
+// register adds the algo, names already registered are suffixed with #2, #3..
+func register(algos []algoSpec, name string, newInstance func() interface{}) []algoSpec {
+	unique := name
+	for n := 2; isRegistered(algos, unique); n++ {
+		unique = fmt.Sprintf("%s#%d", name, n)
+	}
+	return append(algos, algoSpec{name: unique, newInstance: newInstance})
+}
+
+func isRegistered(algos []algoSpec, name string) bool {
+	for _, a := range algos {
+		if a.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// btConfig carries the simulation settings from the engine to the runners
+type btConfig struct {
+	fillModel     FillModel
+	latencyModel  LatencyModel
+	seed          int64
+	queuePosition bool
+	costModel     CostModel
+	portfolio     *Portfolio
+	universe      map[string]Universe
+}
+
+// SetFillModel sets the fill model used for the marketable orders, DepthFill
+// by default
+func (bt *BacktestEngine) SetFillModel(m FillModel) {
+	bt.config.fillModel = m
+}
+
+// SetLatencyModel sets the order latency, orders are eligible to fill on
+// the next tick by default
+func (bt *BacktestEngine) SetLatencyModel(m LatencyModel) {
+	bt.config.latencyModel = m
+}
+
+// SetQueuePosition enables the queue position model for limit orders, a
+// limit order then waits behind the quantity displayed at its price and fills
+// only once the traded volume at the price consumes the queue. By default
+// limit orders fill as soon as LTP touches the price.
+func (bt *BacktestEngine) SetQueuePosition(enable bool) {
+	bt.config.queuePosition = enable
+}
+
+// SetCostModel sets the transaction charges levied on the fills, no charges
+// by default
+func (bt *BacktestEngine) SetCostModel(m CostModel) {
+	bt.config.costModel = m
+}
+
+// SetPortfolio shares the capital of the portfolio across all the algo
+// instances. Books not allocating cash on setup are allocated the capital,
+// capped by the algo and symbol limits of the portfolio.
+func (bt *BacktestEngine) SetPortfolio(p *Portfolio) {
+	bt.config.portfolio = p
+}
+
+// SetUniverse limits the symbols the algo is instantiated for, by default
+// the algo is instantiated for every tradable symbol in the feed
+func (bt *BacktestEngine) SetUniverse(algoName string, u Universe) {
+	if bt.config.universe == nil {
+		bt.config.universe = make(map[string]Universe)
 	}
-	bt.algos = append(bt.algos, reflect.TypeOf(a))
+	bt.config.universe[algoName] = u
+}
+
+// SetSeed sets the seed for the random models
+func (bt *BacktestEngine) SetSeed(seed int64) {
+	bt.config.seed = seed
+}
+
+// RegisterAlgo BacktestEngine, every instance starts as a copy of the
+// registered value, so the fields set carry the parameters of the algo. The
+// algo is named after its type, registering the type again with other
+// parameters names it Type#2, Type#3 and so on.
+func (bt *BacktestEngine) RegisterAlgo(a interface{}) {
+	name, newInstance := copyOf(a)
+	bt.algos = register(bt.algos, name, newInstance)
+}
+
+// RegisterAlgoAs registers the algo with the parameter set name
+func (bt *BacktestEngine) RegisterAlgoAs(name string, a interface{}) {
+	_, newInstance := copyOf(a)
+	bt.algos = register(bt.algos, name, newInstance)
+}
+
+// RegisterAlgoFactory registers the algo created by the factory, called for
+// every instance
+func (bt *BacktestEngine) RegisterAlgoFactory(name string, factory func() AlgoStrategy) {
+	bt.algos = register(bt.algos, name, func() interface{} {
+		return factory()
+	})
+}
+
+// RegisterPortfolioAlgo registers a PortfolioStrategy, instantiated once
+// for the run instead of once per symbol
+func (bt *BacktestEngine) RegisterPortfolioAlgo(a interface{}) {
+	name, newInstance := copyOf(a)
+	bt.portfolioAlgos = register(bt.portfolioAlgos, name, newInstance)
 }
 
 // RunAlgoBetweenDate method
 func (bt *BacktestEngine) RunAlgoBetweenDate(feed *kstreamdb.DB, oms OrderManager, algoName string, startDate time.Time, endDate time.Time) {
-	selectedAlgo := make([]reflect.Type, 0)
+	selectedAlgo := make([]algoSpec, 0)
 	for _, a := range bt.algos {
-		if a.Name() == algoName {
+		if a.name == algoName {
 			selectedAlgo = append(selectedAlgo, a)
 			break
 		}
 	}
+	selectedPortfolioAlgo := make([]algoSpec, 0)
+	for _, a := range bt.portfolioAlgos {
+		if a.name == algoName {
+			selectedPortfolioAlgo = append(selectedPortfolioAlgo, a)
+			break
+		}
+	}
 
-	if len(selectedAlgo) == 0 {
+	if len(selectedAlgo) == 0 && len(selectedPortfolioAlgo) == 0 {
 		return
 	}
+	bt.run(feed, selectedAlgo, selectedPortfolioAlgo, startDate, endDate)
+}
 
-	dates, _ := feed.GetDates()
-	dayRunner := btDayRunner{}
-	dayRunner.setup(selectedAlgo)
-	log.SetFlags(log.LstdFlags | log.Lmicroseconds)
-	var wg sync.WaitGroup
-
-	for _, dt := range dates {
-		if dt.Format("20060102") < startDate.Format("20060102") || dt.Format("20060102") > endDate.Format("20060102") {
-			continue
-		}
-
-		log.Printf("[%s] Loading data", dt.Format("2006/01/02"))
-		data, _ := feed.LoadDataForDate(dt)
-		log.Printf("[%s] %d ticks loaded", dt.Format("2006/01/02"), len(data))
-		wg.Wait()
-		wg.Add(1)
-		go func(d time.Time) {
-			dayRunner.run(d, data)
-			wg.Done()
-			log.Printf("[%s] Completed", d.Format("2006/01/02"))
-		}(dt)
-	}
-	wg.Wait()
-	dayRunner.exit()
-	//pull the orders from the run
-	bt.orders = dayRunner.popOrders()
-	// analyze the orders and generate scores for algo
-	bt.scores = calculateAlgoScores(bt.orders)
+// RunBetweenDate runs all the algos registered between the dates
+func (bt *BacktestEngine) RunBetweenDate(feed *kstreamdb.DB, oms OrderManager, startDate time.Time, endDate time.Time) {
+	bt.run(feed, bt.algos, bt.portfolioAlgos, startDate, endDate)
 }
 
 // Run BacktestEngine
 func (bt *BacktestEngine) Run(feed *kstreamdb.DB, oms OrderManager) {
-	// Load All Data into memory
+	bt.run(feed, bt.algos, bt.portfolioAlgos, time.Time{}, time.Time{})
+}
+
+// run the algos over the days of the feed between the dates, zero dates
+// leave the range open
+func (bt *BacktestEngine) run(feed *kstreamdb.DB, algos []algoSpec, portfolioAlgos []algoSpec, startDate time.Time, endDate time.Time) {
 	dates, _ := feed.GetDates()
 	dayRunner := btDayRunner{}
-	dayRunner.setup(bt.algos)
+	dayRunner.setup(algos, portfolioAlgos, bt.config)
 	log.SetFlags(log.LstdFlags | log.Lmicroseconds)
 	var wg sync.WaitGroup
 
 	for _, dt := range dates {
+		if !startDate.IsZero() && dt.Format("20060102") < startDate.Format("20060102") {
+			continue
+		}
+		if !endDate.IsZero() && dt.Format("20060102") > endDate.Format("20060102") {
+			continue
+		}
+
 		log.Printf("[%s] Loading data", dt.Format("2006/01/02"))
 		data, _ := feed.LoadDataForDate(dt)
 		log.Printf("[%s] %d ticks loaded", dt.Format("2006/01/02"), len(data))
@@ -101,3 +219,9 @@ func (bt *BacktestEngine) Run(feed *kstreamdb.DB, oms OrderManager) {
 func (bt *BacktestEngine) Scores() []AlgoScore {
 	return bt.scores
 }
+
+// TotalScores returns the scores of the algos across all the symbols traded,
+// with Symbol set to AllSymbols
+func (bt *BacktestEngine) TotalScores() []AlgoScore {
+	return calculateAlgoTotals(bt.orders)
+}
@@ -0,0 +1,83 @@
+package malgova
+
+import "testing"
+
+// paramAlgo is an algo with a parameter set by its fields
+type paramAlgo struct {
+	scriptAlgo
+	Period int
+}
+
+func names(algos []algoSpec) []string {
+	n := make([]string, 0, len(algos))
+	for _, a := range algos {
+		n = append(n, a.name)
+	}
+	return n
+}
+
+func TestRegisterAlgo(t *testing.T) {
+	bt := new(BacktestEngine)
+	bt.RegisterAlgo(paramAlgo{Period: 10})
+	bt.RegisterAlgo(&paramAlgo{Period: 20})
+	bt.RegisterAlgoAs("fast", paramAlgo{Period: 5})
+	bt.RegisterAlgoAs("fast", paramAlgo{Period: 3})
+	bt.RegisterAlgo(paramAlgo{Period: 30})
+	want := []string{"paramAlgo", "paramAlgo#2", "fast", "fast#2", "paramAlgo#3"}
+	got := names(bt.algos)
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("got %v, want %v", got, want)
+			break
+		}
+	}
+	for i, period := range []int{10, 20, 5, 3, 30} {
+		if p := bt.algos[i].newInstance().(*paramAlgo).Period; p != period {
+			t.Errorf("%s period %d, want %d", bt.algos[i].name, p, period)
+		}
+	}
+
+	bt.RegisterPortfolioAlgo(paramAlgo{})
+	if got := names(bt.portfolioAlgos); len(got) != 1 || got[0] != "paramAlgo" {
+		t.Errorf("portfolio algos %v, named apart from the algos", got)
+	}
+}
+
+func TestCopyOf(t *testing.T) {
+	registered := &paramAlgo{Period: 10}
+	name, newInstance := copyOf(registered)
+	if name != "paramAlgo" {
+		t.Errorf("name %s", name)
+	}
+	a, b := newInstance().(*paramAlgo), newInstance().(*paramAlgo)
+	a.Period = 20
+	a.n = 5
+	if b.Period != 10 || b.n != 0 || registered.Period != 10 {
+		t.Errorf("copies share the fields: %d %d %d", b.Period, b.n, registered.Period)
+	}
+}
+
+func TestRegisterAlgoFactory(t *testing.T) {
+	calls := 0
+	bt := new(BacktestEngine)
+	bt.RegisterAlgoFactory("scripted", func() AlgoStrategy {
+		calls++
+		return &scriptAlgo{}
+	})
+	bt.RegisterAlgoFactory("scripted", func() AlgoStrategy { return &scriptAlgo{} })
+	if got := names(bt.algos); len(got) != 2 || got[0] != "scripted" || got[1] != "scripted#2" {
+		t.Errorf("names %v", got)
+	}
+	for _, symbol := range []string{"SBIN", "INFY"} {
+		a := newAlgoInstance(bt.algos[0], symbol, btConfig{})
+		if a.ID() != "scripted::"+symbol {
+			t.Errorf("instance id %s", a.ID())
+		}
+	}
+	if calls != 2 {
+		t.Errorf("factory called %d times, want once per instance", calls)
+	}
+}
@@ -1,12 +1,126 @@
 package malgova
 
+import (
+	"container/heap"
+	"sort"
+
+	"github.com/sivamgr/kstreamdb"
+)
+
+// btTickManager indexes the ticks of the day by symbol, algo instances walk
+// the ticks of the symbols they subscribe to, in the order of the feed
 type btTickManager struct {
-	observerAlgoIDs []string
+	ticks   []kstreamdb.TickData
+	symbols map[string][]int
+}
+
+func newTickManager(ticks []kstreamdb.TickData) *btTickManager {
+	s := new(btTickManager)
+	s.ticks = ticks
+	s.symbols = make(map[string][]int)
+	for i, t := range ticks {
+		s.symbols[t.TradingSymbol] = append(s.symbols[t.TradingSymbol], i)
+	}
+	return s
+}
+
+// count returns the ticks of the symbol
+func (s *btTickManager) count(symbol string) int {
+	return len(s.symbols[symbol])
 }
 
-func (s *btTickManager) addObserver(algoID string) {
-	if s.observerAlgoIDs == nil {
-		s.observerAlgoIDs = make([]string, 0)
+// btTickCursor walks the ticks of the subscribed symbols in feed order, by
+// merging the tick indexes of the symbols on a heap of their next ticks
+type btTickCursor struct {
+	mgr   *btTickManager
+	heads tickHeads
+	last  int
+}
+
+// tickHead is the position of the cursor in the tick index of the symbol
+type tickHead struct {
+	symbol string
+	index  []int
+	pos    int
+}
+
+// tickHeads is a min heap of the symbols by the index of their next tick
+type tickHeads []*tickHead
+
+func (h tickHeads) Len() int            { return len(h) }
+func (h tickHeads) Less(i, j int) bool  { return h[i].index[h[i].pos] < h[j].index[h[j].pos] }
+func (h tickHeads) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *tickHeads) Push(x interface{}) { *h = append(*h, x.(*tickHead)) }
+func (h *tickHeads) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+func newTickCursor(mgr *btTickManager, symbols []string) *btTickCursor {
+	c := &btTickCursor{
+		mgr:  mgr,
+		last: -1,
+	}
+	c.subscribe(symbols)
+	return c
+}
+
+// expand returns the subscribed symbols, with AllSymbols expanded to every
+// symbol of the day
+func (s *btTickManager) expand(symbols []string) []string {
+	for _, symbol := range symbols {
+		if symbol == AllSymbols {
+			all := make([]string, 0, len(s.symbols))
+			for symbol := range s.symbols {
+				all = append(all, symbol)
+			}
+			return all
+		}
+	}
+	return symbols
+}
+
+// subscribe adds the symbols to the cursor, from the current tick onwards.
+// Symbols dropped from the list are unsubscribed.
+func (c *btTickCursor) subscribe(symbols []string) {
+	current := make(map[string]*tickHead, len(c.heads))
+	for _, h := range c.heads {
+		current[h.symbol] = h
+	}
+	heads := make(tickHeads, 0, len(symbols))
+	subscribed := make(map[string]bool)
+	for _, symbol := range c.mgr.expand(symbols) {
+		if subscribed[symbol] {
+			continue
+		}
+		subscribed[symbol] = true
+		if h, ok := current[symbol]; ok {
+			heads = append(heads, h)
+			continue
+		}
+		index := c.mgr.symbols[symbol]
+		if pos := sort.SearchInts(index, c.last+1); pos < len(index) {
+			heads = append(heads, &tickHead{symbol: symbol, index: index, pos: pos})
+		}
+	}
+	heap.Init(&heads)
+	c.heads = heads
+}
+
+// next returns the next tick of the subscribed symbols, false when done
+func (c *btTickCursor) next() (kstreamdb.TickData, bool) {
+	if len(c.heads) == 0 {
+		return kstreamdb.TickData{}, false
+	}
+	h := c.heads[0]
+	c.last = h.index[h.pos]
+	h.pos++
+	if h.pos < len(h.index) {
+		heap.Fix(&c.heads, 0)
+	} else {
+		heap.Pop(&c.heads)
 	}
-	s.observerAlgoIDs = append(s.observerAlgoIDs, algoID)
+	return c.mgr.ticks[c.last], true
 }
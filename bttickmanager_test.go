@@ -0,0 +1,89 @@
+package malgova
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sivamgr/kstreamdb"
+)
+
+// feedOf returns a tick per symbol of the space separated list, in order
+func feedOf(symbols string) *btTickManager {
+	ticks := make([]kstreamdb.TickData, 0)
+	for i, symbol := range strings.Fields(symbols) {
+		ticks = append(ticks, of(symbol, testTick(i, 100, depth(99.5, 1000), depth(100.5, 1000))))
+	}
+	return newTickManager(ticks)
+}
+
+// walk returns the symbols of the next n ticks of the cursor, all when n is
+// negative
+func walk(c *btTickCursor, n int) []string {
+	seen := make([]string, 0)
+	for t, ok := c.next(); ok; t, ok = c.next() {
+		seen = append(seen, t.TradingSymbol)
+		if len(seen) == n {
+			break
+		}
+	}
+	return seen
+}
+
+func TestTickCursor(t *testing.T) {
+	mgr := feedOf("SBIN INFY SBIN TCS INFY SBIN TCS")
+	tests := []struct {
+		name    string
+		symbols []string
+		want    string
+	}{
+		{"one symbol", []string{"SBIN"}, "SBIN SBIN SBIN"},
+		{"feed order across symbols", []string{"TCS", "SBIN"}, "SBIN SBIN TCS SBIN TCS"},
+		{"duplicate symbols", []string{"INFY", "INFY"}, "INFY INFY"},
+		{"symbol not in the feed", []string{"WIPRO"}, ""},
+		{"all symbols", []string{AllSymbols}, "SBIN INFY SBIN TCS INFY SBIN TCS"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := walk(newTickCursor(mgr, tt.symbols), -1); strings.Join(got, " ") != tt.want {
+				t.Errorf("got %v, want %s", got, tt.want)
+			}
+		})
+	}
+	if mgr.count("SBIN") != 3 || mgr.count("WIPRO") != 0 {
+		t.Errorf("count SBIN %d WIPRO %d", mgr.count("SBIN"), mgr.count("WIPRO"))
+	}
+}
+
+func TestTickCursorSubscribe(t *testing.T) {
+	mgr := feedOf("SBIN INFY SBIN TCS INFY SBIN TCS")
+	c := newTickCursor(mgr, []string{"SBIN"})
+	if got := walk(c, 2); !reflect.DeepEqual(got, []string{"SBIN", "SBIN"}) {
+		t.Fatalf("before subscribe %v", got)
+	}
+	// the ticks of INFY before the current tick are not replayed
+	c.subscribe([]string{"SBIN", "INFY", "TCS"})
+	if got := walk(c, 2); !reflect.DeepEqual(got, []string{"TCS", "INFY"}) {
+		t.Fatalf("after subscribe %v", got)
+	}
+	c.subscribe([]string{"TCS"})
+	if got := walk(c, -1); !reflect.DeepEqual(got, []string{"TCS"}) {
+		t.Errorf("after unsubscribe %v", got)
+	}
+}
+
+func TestSubscribeMidDay(t *testing.T) {
+	mgr := feedOf("SBIN INFY SBIN INFY SBIN INFY")
+	algo := &scriptAlgo{script: map[int]func(b *Book){
+		1: func(b *Book) { b.Subscribe("INFY") },
+		3: func(b *Book) { b.Unsubscribe("INFY") },
+	}}
+	a := newAlgoInstance(algoSpec{name: "script", newInstance: func() interface{} { return algo }}, testSymbol, btConfig{})
+	a.run(mgr)
+	if want := []string{"SBIN", "SBIN", "INFY", "SBIN"}; !reflect.DeepEqual(algo.seen, want) {
+		t.Errorf("got %v, want %v", algo.seen, want)
+	}
+	if got := a.book.Subscriptions(); !reflect.DeepEqual(got, []string{testSymbol}) {
+		t.Errorf("subscriptions %v", got)
+	}
+}
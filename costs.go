@@ -0,0 +1,110 @@
+package malgova
+
+import (
+	"math"
+)
+
+// CostModel Interface, returns the transaction charges of an order for the
+// quantity filled at the average price, qty is negative for sells. An order
+// filled in parts is charged the increase of its charges on each fill.
+type CostModel interface {
+	Charges(symbol string, qty int, price float64) float64
+}
+
+// ChargesModel struct, the Indian transaction charges. Percentages are on the
+// turnover of the order, brokerage is the flat amount per order or the percent
+// of turnover capped at BrokerageCap, whichever is applicable. GST applies on
+// brokerage, exchange charges and SEBI fees.
+type ChargesModel struct {
+	BrokerageFlat       float64
+	BrokeragePercent    float64
+	BrokerageCap        float64
+	STTBuyPercent       float64
+	STTSellPercent      float64
+	ExchangePercent     float64
+	SEBIPerCrore        float64
+	GSTPercent          float64
+	StampDutyBuyPercent float64
+}
+
+// Charges presets for NSE
+var (
+	NSEEquityIntraday = ChargesModel{
+		BrokeragePercent:    0.03,
+		BrokerageCap:        20,
+		STTSellPercent:      0.025,
+		ExchangePercent:     0.00345,
+		SEBIPerCrore:        10,
+		GSTPercent:          18,
+		StampDutyBuyPercent: 0.003,
+	}
+	NSEEquityDelivery = ChargesModel{
+		STTBuyPercent:       0.1,
+		STTSellPercent:      0.1,
+		ExchangePercent:     0.00345,
+		SEBIPerCrore:        10,
+		GSTPercent:          18,
+		StampDutyBuyPercent: 0.015,
+	}
+	NSEFutures = ChargesModel{
+		BrokeragePercent:    0.03,
+		BrokerageCap:        20,
+		STTSellPercent:      0.01,
+		ExchangePercent:     0.002,
+		SEBIPerCrore:        10,
+		GSTPercent:          18,
+		StampDutyBuyPercent: 0.002,
+	}
+	NSEOptions = ChargesModel{
+		BrokerageFlat:       20,
+		STTSellPercent:      0.05,
+		ExchangePercent:     0.053,
+		SEBIPerCrore:        10,
+		GSTPercent:          18,
+		StampDutyBuyPercent: 0.003,
+	}
+)
+
+// Charges method
+func (m ChargesModel) Charges(symbol string, qty int, price float64) float64 {
+	turnover := math.Abs(float64(qty)) * price
+
+	brokerage := m.BrokerageFlat
+	if m.BrokeragePercent > 0 {
+		brokerage = turnover * m.BrokeragePercent / 100
+		if m.BrokerageCap > 0 && brokerage > m.BrokerageCap {
+			brokerage = m.BrokerageCap
+		}
+	}
+
+	stt := turnover * m.STTSellPercent / 100
+	stamp := 0.0
+	if qty > 0 {
+		stt = turnover * m.STTBuyPercent / 100
+		stamp = turnover * m.StampDutyBuyPercent / 100
+	}
+	exchange := turnover * m.ExchangePercent / 100
+	sebi := turnover * m.SEBIPerCrore / 1e7
+	gst := (brokerage + exchange + sebi) * m.GSTPercent / 100
+	return brokerage + stt + exchange + sebi + gst + stamp
+}
+
+// SegmentCharges applies the charges model by the segment of the symbol
+type SegmentCharges map[string]ChargesModel
+
+// NSEIntradayCharges returns the intraday charges for equity, futures and options
+func NSEIntradayCharges() SegmentCharges {
+	return SegmentCharges{
+		SegmentEquity:  NSEEquityIntraday,
+		SegmentFutures: NSEFutures,
+		SegmentOptions: NSEOptions,
+	}
+}
+
+// Charges method
+func (m SegmentCharges) Charges(symbol string, qty int, price float64) float64 {
+	if c, ok := m[SegmentOf(symbol)]; ok {
+		return c.Charges(symbol, qty, price)
+	}
+	return 0
+}
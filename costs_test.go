@@ -0,0 +1,89 @@
+package malgova
+
+import (
+	"testing"
+
+	"github.com/sivamgr/kstreamdb"
+)
+
+func TestChargesModel(t *testing.T) {
+	const option = "NIFTY20JUL11000CE"
+	tests := []struct {
+		name   string
+		model  CostModel
+		symbol string
+		qty    int
+		price  float64
+		want   float64
+	}{
+		{"options buy", NSEOptions, option, 50, 100, 20 + 2.65 + 0.005 + 0.18*(20+2.65+0.005) + 0.15},
+		{"options sell", NSEOptions, option, -50, 100, 20 + 2.5 + 2.65 + 0.005 + 0.18*(20+2.65+0.005)},
+		{"equity intraday buy", NSEEquityIntraday, "SBIN", 100, 100, 3 + 0.345 + 0.01 + 0.18*(3+0.345+0.01) + 0.3},
+		{"futures brokerage cap", NSEFutures, "NIFTY20JULFUT", 1000, 100, 20 + 2 + 0.1 + 0.18*(20+2+0.1) + 2},
+		{"segment of the symbol", NSEIntradayCharges(), option, 50, 100, NSEOptions.Charges(option, 50, 100)},
+		{"segment without charges", SegmentCharges{SegmentEquity: NSEEquityIntraday}, option, 50, 100, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wantFloat(t, "charges", tt.model.Charges(tt.symbol, tt.qty, tt.price), tt.want)
+		})
+	}
+}
+
+func TestOrderCharges(t *testing.T) {
+	const option = "NIFTY20JUL11000CE"
+	tests := []struct {
+		name   string
+		symbol string
+		model  CostModel
+		fills  int
+		want   float64
+	}{
+		{
+			name:   "options order filled at once",
+			symbol: option,
+			model:  NSEOptions,
+			fills:  1,
+			want:   NSEOptions.Charges(option, 50, 100),
+		},
+		{
+			name:   "options order filled in parts pays the brokerage once",
+			symbol: option,
+			model:  NSEOptions,
+			fills:  5,
+			want:   NSEOptions.Charges(option, 50, 100),
+		},
+		{
+			name:   "brokerage cap applies to the order",
+			symbol: "NIFTY20JULFUT",
+			model:  NSEFutures,
+			fills:  5,
+			want:   NSEFutures.Charges("NIFTY20JULFUT", 50, 100),
+		},
+		{
+			name:   "segment charges by symbol",
+			symbol: option,
+			model:  NSEIntradayCharges(),
+			fills:  5,
+			want:   NSEOptions.Charges(option, 50, 100),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ticks := []kstreamdb.TickData{quietTick()}
+			for i := 1; i <= tt.fills; i++ {
+				ticks = append(ticks, testTick(i, 100, depth(99.5, 1000), depth(100, float32(50/tt.fills))))
+			}
+			c := tickCase{
+				symbol: tt.symbol,
+				config: btConfig{costModel: tt.model},
+				script: map[int]func(b *Book){0: func(b *Book) { b.Buy(50) }},
+				ticks:  ticks,
+			}
+			a := c.run()
+			wantOrder(t, &a.book, 1, OrderStatusFilled, 50, 100)
+			wantFloat(t, "charges", a.book.Charges, tt.want)
+			wantFloat(t, "cash", a.book.Cash, 1000000-5000-tt.want)
+		})
+	}
+}
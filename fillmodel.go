@@ -0,0 +1,185 @@
+package malgova
+
+import (
+	"math"
+
+	"github.com/sivamgr/kstreamdb"
+)
+
+// FillModel Interface, decides the quantity and the price a marketable order
+// gets filled at against the tick. Used for market orders and stop orders on
+// trigger. Zero quantity leaves the order working, and the limit price of
+// stop-limit orders must be respected.
+type FillModel interface {
+	Fill(o Order, t kstreamdb.TickData) (int, float64)
+}
+
+// TouchFill fills the entire quantity at the best opposite price, or LTP when
+// the tick has no depth
+type TouchFill struct{}
+
+// Fill method
+func (m TouchFill) Fill(o Order, t kstreamdb.TickData) (int, float64) {
+	price := marketPrice(o.Side, t)
+	if price <= 0 || !o.isMarketable(price) {
+		return 0, 0
+	}
+	return o.PendingQuantity(), price
+}
+
+// LTPFill fills the entire quantity at the last traded price
+type LTPFill struct{}
+
+// Fill method
+func (m LTPFill) Fill(o Order, t kstreamdb.TickData) (int, float64) {
+	price := float64(t.LastPrice)
+	if price <= 0 || !o.isMarketable(price) {
+		return 0, 0
+	}
+	return o.PendingQuantity(), price
+}
+
+// DepthFill walks the opposite depth consuming the quantity available at each
+// level, filling at the volume weighted price and leaving the rest working.
+// Ticks without depth are filled as TouchFill. This is the default fill model.
+type DepthFill struct{}
+
+// Fill method
+func (m DepthFill) Fill(o Order, t kstreamdb.TickData) (int, float64) {
+	if !hasDepth(o.Side, t) {
+		return TouchFill{}.Fill(o, t)
+	}
+	return walkDepth(&o, t)
+}
+
+// FixedSlippage fills as the underlying model, with the price moved against
+// the order by fixed basis points
+type FixedSlippage struct {
+	Model FillModel
+	Bps   float64
+}
+
+// Fill method
+func (m FixedSlippage) Fill(o Order, t kstreamdb.TickData) (int, float64) {
+	qty, price := fillWith(m.Model, o, t)
+	return slip(o, qty, price, price*m.Bps/10000)
+}
+
+// SpreadSlippage fills as the underlying model, with the price moved against
+// the order by a fraction of the bid-ask spread
+type SpreadSlippage struct {
+	Model  FillModel
+	Factor float64
+}
+
+// Fill method
+func (m SpreadSlippage) Fill(o Order, t kstreamdb.TickData) (int, float64) {
+	qty, price := fillWith(m.Model, o, t)
+	spread := 0.0
+	if t.Ask[0].Price > 0 && t.Bid[0].Price > 0 {
+		spread = float64(t.Ask[0].Price - t.Bid[0].Price)
+	}
+	return slip(o, qty, price, m.Factor*math.Max(spread, 0))
+}
+
+// VolatilitySlippage fills as the underlying model, with the price moved
+// against the order by a fraction of the day range, high minus low, as the
+// measure of volatility
+type VolatilitySlippage struct {
+	Model  FillModel
+	Factor float64
+}
+
+// Fill method
+func (m VolatilitySlippage) Fill(o Order, t kstreamdb.TickData) (int, float64) {
+	qty, price := fillWith(m.Model, o, t)
+	dayRange := float64(t.DayHighPrice - t.DayLowPrice)
+	return slip(o, qty, price, m.Factor*math.Max(dayRange, 0))
+}
+
+// fillWith fills using the model, DepthFill when nil
+func fillWith(m FillModel, o Order, t kstreamdb.TickData) (int, float64) {
+	if m == nil {
+		m = DepthFill{}
+	}
+	return m.Fill(o, t)
+}
+
+// slip moves the price against the order, capped at the limit price
+func slip(o Order, qty int, price float64, amount float64) (int, float64) {
+	if qty == 0 {
+		return 0, 0
+	}
+	if o.Side == SideBuy {
+		price += amount
+	} else {
+		price -= amount
+	}
+	if o.isLimit() && !o.isMarketable(price) {
+		price = o.Price
+	}
+	return qty, price
+}
+
+// depthLevels returns the opposite depth for the side
+func depthLevels(side OrderSide, t kstreamdb.TickData) [5]kstreamdb.DepthItem {
+	if side == SideSell {
+		return t.Bid
+	}
+	return t.Ask
+}
+
+// hasDepth checks if the tick carries the opposite depth for the side
+func hasDepth(side OrderSide, t kstreamdb.TickData) bool {
+	for _, l := range depthLevels(side, t) {
+		if l.Price > 0 && l.Quantity > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// touchPrice returns the best opposite price for the side, zero when depth is empty
+func touchPrice(side OrderSide, t kstreamdb.TickData) float64 {
+	return float64(depthLevels(side, t)[0].Price)
+}
+
+// marketPrice returns the best opposite price, or LTP when depth is empty
+func marketPrice(side OrderSide, t kstreamdb.TickData) float64 {
+	price := touchPrice(side, t)
+	if price <= 0 {
+		price = float64(t.LastPrice)
+	}
+	return price
+}
+
+// walkDepth consumes the opposite depth for the pending quantity of the
+// order, limited to the limit price for limit orders. Returns the quantity
+// available and the volume weighted price, zero quantity when the tick has
+// no depth or nothing within the limit.
+func walkDepth(o *Order, t kstreamdb.TickData) (int, float64) {
+	pending := o.PendingQuantity()
+	qty := 0
+	value := 0.0
+	for _, l := range depthLevels(o.Side, t) {
+		if qty >= pending {
+			break
+		}
+		if l.Price <= 0 || l.Quantity == 0 {
+			continue
+		}
+		if !o.isMarketable(float64(l.Price)) {
+			break
+		}
+		take := int(l.Quantity)
+		if take > pending-qty {
+			take = pending - qty
+		}
+		qty += take
+		value += float64(take) * float64(l.Price)
+	}
+	if qty == 0 {
+		return 0, 0
+	}
+	return qty, value / float64(qty)
+}
@@ -0,0 +1,74 @@
+package malgova
+
+import (
+	"testing"
+
+	"github.com/sivamgr/kstreamdb"
+)
+
+func TestDepthFills(t *testing.T) {
+	runTickCases(t, []tickCase{
+		{
+			name:   "market order walks the depth",
+			script: map[int]func(b *Book){0: func(b *Book) { b.Buy(150) }},
+			ticks:  []kstreamdb.TickData{quietTick(), testTick(1, 100, depth(99, 100), depth(100, 100, 101, 100))},
+			check: func(t *testing.T, b *Book) {
+				wantOrder(t, b, 1, OrderStatusFilled, 150, (100*100+101*50)/150.0)
+				if b.Position != 150 {
+					t.Errorf("position %d, want 150", b.Position)
+				}
+			},
+		},
+		{
+			name:   "partial depth fill keeps working",
+			script: map[int]func(b *Book){0: func(b *Book) { b.Buy(300) }},
+			ticks:  []kstreamdb.TickData{quietTick(), testTick(1, 100, depth(99, 100), depth(100, 100, 101, 100))},
+			check: func(t *testing.T, b *Book) {
+				wantOrder(t, b, 1, OrderStatusOpen, 200, 100.5)
+			},
+		},
+		{
+			name:   "partial depth fill completes on the next tick",
+			script: map[int]func(b *Book){0: func(b *Book) { b.Buy(300) }},
+			ticks: []kstreamdb.TickData{quietTick(),
+				testTick(1, 100, depth(99, 100), depth(100, 100, 101, 100)),
+				testTick(2, 102, depth(101, 100), depth(102, 100))},
+			check: func(t *testing.T, b *Book) {
+				wantOrder(t, b, 1, OrderStatusFilled, 300, 101)
+			},
+		},
+		{
+			name:   "marketable limit walks the depth",
+			script: map[int]func(b *Book){0: func(b *Book) { b.BuyLimit(500, 105) }},
+			ticks:  []kstreamdb.TickData{quietTick(), testTick(1, 100, depth(99, 100), depth(100, 10, 101, 10))},
+			check: func(t *testing.T, b *Book) {
+				wantOrder(t, b, 1, OrderStatusOpen, 20, 100.5)
+			},
+		},
+		{
+			name:   "marketable sell limit stops at the limit price",
+			script: map[int]func(b *Book){0: func(b *Book) { b.SellLimit(300, 99) }},
+			ticks:  []kstreamdb.TickData{quietTick(), testTick(1, 100, depth(100, 100, 99, 100, 98, 100), depth(100.5, 100))},
+			check: func(t *testing.T, b *Book) {
+				wantOrder(t, b, 1, OrderStatusOpen, 200, 99.5)
+			},
+		},
+		{
+			name:   "marketable limit walks the depth in queue mode",
+			config: btConfig{queuePosition: true},
+			script: map[int]func(b *Book){0: func(b *Book) { b.BuyLimit(500, 105) }},
+			ticks:  []kstreamdb.TickData{quietTick(), testTick(1, 100, depth(99, 100), depth(100, 10, 101, 10))},
+			check: func(t *testing.T, b *Book) {
+				wantOrder(t, b, 1, OrderStatusOpen, 20, 100.5)
+			},
+		},
+		{
+			name:   "resting limit fills at its price when LTP touches it",
+			script: map[int]func(b *Book){0: func(b *Book) { b.BuyLimit(500, 99) }},
+			ticks:  []kstreamdb.TickData{quietTick(), testTick(1, 99, depth(98.5, 100), depth(99.5, 100))},
+			check: func(t *testing.T, b *Book) {
+				wantOrder(t, b, 1, OrderStatusFilled, 500, 99)
+			},
+		},
+	})
+}
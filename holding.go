@@ -0,0 +1,127 @@
+package malgova
+
+import (
+	"math"
+	"sort"
+)
+
+// Holding struct, the position held in a symbol
+type Holding struct {
+	Symbol          string
+	Position        int
+	AvgPrice        float64
+	RealizedPnl     float64
+	UnrealizedPnl   float64
+	Turnover        float64
+	MaxPositionHeld int
+}
+
+// applyFill updates the position and the pnl for the fill, qty is negative
+// for sells
+func (h *Holding) applyFill(qty int, price float64) {
+	h.Turnover += math.Abs(float64(qty)) * price
+
+	pos := h.Position
+	absPos := math.Abs(float64(pos))
+	absQty := math.Abs(float64(qty))
+	if pos == 0 || (pos > 0) == (qty > 0) {
+		// scaling in
+		h.AvgPrice = (h.AvgPrice*absPos + price*absQty) / (absPos + absQty)
+	} else {
+		// scaling out, or flipping the position
+		closing := math.Min(absQty, absPos)
+		if pos > 0 {
+			h.RealizedPnl += closing * (price - h.AvgPrice)
+		} else {
+			h.RealizedPnl += closing * (h.AvgPrice - price)
+		}
+		if absQty > absPos {
+			h.AvgPrice = price
+		} else if absQty == absPos {
+			h.AvgPrice = 0
+		}
+	}
+
+	h.Position += qty
+	if p := int(math.Abs(float64(h.Position))); p > h.MaxPositionHeld {
+		h.MaxPositionHeld = p
+	}
+}
+
+// markToMarket updates the unrealized pnl at the last price
+func (h *Holding) markToMarket(ltp float64) {
+	if h.Position == 0 {
+		h.UnrealizedPnl = 0
+	} else if ltp > 0 {
+		h.UnrealizedPnl = float64(h.Position) * (ltp - h.AvgPrice)
+	}
+}
+
+// holding returns the holding of the symbol, created on first use
+func (b *Book) holding(symbol string) *Holding {
+	if b.holdings == nil {
+		b.holdings = make(map[string]*Holding)
+	}
+	h, ok := b.holdings[symbol]
+	if !ok {
+		h = &Holding{Symbol: symbol}
+		b.holdings[symbol] = h
+	}
+	return h
+}
+
+// applyFill updates cash, the holding and the pnl for the fill, qty is
+// negative for sells
+func (b *Book) applyFill(symbol string, qty int, price float64, charges float64) {
+	b.Cash -= price*float64(qty) + charges
+	b.Charges += charges
+	b.holding(symbol).applyFill(qty, price)
+	b.markToMarket()
+}
+
+// markToMarket updates the unrealized pnl of the holdings at the last price,
+// and the book totals
+func (b *Book) markToMarket() {
+	b.RealizedPnl = 0
+	b.UnrealizedPnl = 0
+	b.Turnover = 0
+	for _, h := range b.holdings {
+		h.markToMarket(float64(b.quoteOf(h.Symbol).LastPrice))
+		b.RealizedPnl += h.RealizedPnl
+		b.UnrealizedPnl += h.UnrealizedPnl
+		b.Turnover += h.Turnover
+	}
+	if h, ok := b.holdings[b.symbol]; ok {
+		b.Position = h.Position
+		b.AvgPrice = h.AvgPrice
+		b.MaxPositionHeld = h.MaxPositionHeld
+	}
+}
+
+// PositionOf returns the position held in the symbol
+func (b *Book) PositionOf(symbol string) int {
+	if h, ok := b.holdings[symbol]; ok {
+		return h.Position
+	}
+	return 0
+}
+
+// Holding returns a copy of the holding of the symbol
+func (b *Book) Holding(symbol string) Holding {
+	if h, ok := b.holdings[symbol]; ok {
+		return *h
+	}
+	return Holding{Symbol: symbol}
+}
+
+// Holdings returns copies of the holdings, ordered by symbol
+func (b *Book) Holdings() []Holding {
+	holdings := make([]Holding, 0, len(b.holdings))
+	for _, h := range b.holdings {
+		holdings = append(holdings, *h)
+	}
+	sort.Slice(holdings, func(i, j int) bool {
+		return holdings[i].Symbol < holdings[j].Symbol
+	})
+	return holdings
+}
@@ -0,0 +1,79 @@
+package malgova
+
+import (
+	"testing"
+
+	"github.com/sivamgr/kstreamdb"
+)
+
+func TestHoldingApplyFill(t *testing.T) {
+	type fill struct {
+		qty   int
+		price float64
+	}
+	tests := []struct {
+		name     string
+		fills    []fill
+		position int
+		avgPrice float64
+		realized float64
+		maxHeld  int
+	}{
+		{"scale in", []fill{{100, 100}, {100, 110}}, 200, 105, 0, 200},
+		{"scale out", []fill{{100, 100}, {-40, 110}}, 60, 100, 400, 100},
+		{"close", []fill{{100, 100}, {-100, 95}}, 0, 0, -500, 100},
+		{"short scale out", []fill{{-100, 100}, {50, 90}}, -50, 100, 500, 100},
+		{"flip long to short", []fill{{100, 100}, {-150, 110}}, -50, 110, 1000, 100},
+		{"flip short to long", []fill{{-100, 100}, {300, 105}}, 200, 105, -500, 200},
+		{"scale in after the flip", []fill{{100, 100}, {-150, 110}, {-50, 100}}, -100, 105, 1000, 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := Holding{Symbol: testSymbol}
+			for _, f := range tt.fills {
+				h.applyFill(f.qty, f.price)
+			}
+			if h.Position != tt.position || h.MaxPositionHeld != tt.maxHeld {
+				t.Errorf("position %d max %d, want %d max %d", h.Position, h.MaxPositionHeld, tt.position, tt.maxHeld)
+			}
+			wantFloat(t, "avg price", h.AvgPrice, tt.avgPrice)
+			wantFloat(t, "realized pnl", h.RealizedPnl, tt.realized)
+		})
+	}
+}
+
+func TestHoldingMarkToMarket(t *testing.T) {
+	h := Holding{Symbol: testSymbol}
+	h.applyFill(-100, 100)
+	h.markToMarket(90)
+	wantFloat(t, "short unrealized pnl", h.UnrealizedPnl, 1000)
+	h.markToMarket(0)
+	wantFloat(t, "unrealized pnl without a price", h.UnrealizedPnl, 1000)
+	h.applyFill(100, 95)
+	h.markToMarket(90)
+	wantFloat(t, "flat unrealized pnl", h.UnrealizedPnl, 0)
+}
+
+func TestBookPnlOnFlip(t *testing.T) {
+	runTickCases(t, []tickCase{
+		{
+			name: "flip realizes the pnl of the closed position",
+			script: map[int]func(b *Book){
+				0: func(b *Book) { b.Buy(100) },
+				1: func(b *Book) { b.Sell(300) },
+			},
+			ticks: []kstreamdb.TickData{quietTick(),
+				testTick(1, 100, depth(99.5, 1000), depth(100, 1000)),
+				testTick(2, 110, depth(110, 1000), depth(110.5, 1000)),
+				testTick(3, 105, depth(104.5, 1000), depth(105, 1000))},
+			check: func(t *testing.T, b *Book) {
+				if b.Position != -200 {
+					t.Errorf("position %d, want -200", b.Position)
+				}
+				wantFloat(t, "avg price", b.AvgPrice, 110)
+				wantFloat(t, "realized pnl", b.RealizedPnl, 1000)
+				wantFloat(t, "unrealized pnl", b.UnrealizedPnl, 200*(110-105))
+			},
+		},
+	})
+}
@@ -0,0 +1,45 @@
+package malgova
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"time"
+)
+
+// LatencyModel Interface, returns the delay between placing the order and the
+// order reaching the exchange. The random source is seeded per algo instance
+// from the engine seed, so runs are reproducible.
+type LatencyModel interface {
+	Latency(o Order, r *rand.Rand) time.Duration
+}
+
+// FixedLatency delays every order by the same duration
+type FixedLatency time.Duration
+
+// Latency method
+func (m FixedLatency) Latency(o Order, r *rand.Rand) time.Duration {
+	return time.Duration(m)
+}
+
+// RandomLatency draws the delay from a normal distribution, clipped at Min
+type RandomLatency struct {
+	Mean   time.Duration
+	StdDev time.Duration
+	Min    time.Duration
+}
+
+// Latency method
+func (m RandomLatency) Latency(o Order, r *rand.Rand) time.Duration {
+	d := m.Mean + time.Duration(r.NormFloat64()*float64(m.StdDev))
+	if d < m.Min {
+		return m.Min
+	}
+	return d
+}
+
+// newInstanceRand returns the random source for the algo instance
+func newInstanceRand(seed int64, instanceID string) *rand.Rand {
+	h := fnv.New64a()
+	h.Write([]byte(instanceID))
+	return rand.New(rand.NewSource(seed ^ int64(h.Sum64())))
+}
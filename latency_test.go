@@ -0,0 +1,100 @@
+package malgova
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/sivamgr/kstreamdb"
+)
+
+func TestLatency(t *testing.T) {
+	delayed := btConfig{latencyModel: FixedLatency(2 * time.Second)}
+	runTickCases(t, []tickCase{
+		{
+			name:   "order fills on the next tick without latency",
+			script: map[int]func(b *Book){0: func(b *Book) { b.Buy(10) }},
+			ticks:  []kstreamdb.TickData{quietTick(), testTick(1, 100, depth(99, 100), depth(100, 100))},
+			check: func(t *testing.T, b *Book) {
+				wantOrder(t, b, 1, OrderStatusFilled, 10, 100)
+			},
+		},
+		{
+			name:   "order waits for the latency",
+			config: delayed,
+			script: map[int]func(b *Book){0: func(b *Book) { b.Buy(10) }},
+			ticks:  []kstreamdb.TickData{quietTick(), testTick(1, 100, depth(99, 100), depth(100, 100))},
+			check: func(t *testing.T, b *Book) {
+				wantOrder(t, b, 1, OrderStatusOpen, 0, 0)
+				if o := order(t, b, 1); !o.EligibleAt.Equal(testStart.Add(2 * time.Second)) {
+					t.Errorf("eligible at %v", o.EligibleAt)
+				}
+			},
+		},
+		{
+			name:   "order fills at the tick after the latency",
+			config: delayed,
+			script: map[int]func(b *Book){0: func(b *Book) { b.Buy(10) }},
+			ticks: []kstreamdb.TickData{quietTick(),
+				testTick(1, 100, depth(99, 100), depth(100, 100)),
+				testTick(2, 101, depth(100.5, 100), depth(101, 100))},
+			check: func(t *testing.T, b *Book) {
+				wantOrder(t, b, 1, OrderStatusFilled, 10, 101)
+			},
+		},
+		{
+			name:   "bracket legs are not delayed",
+			config: delayed,
+			script: map[int]func(b *Book){0: func(b *Book) { b.BuyBracket(10, 0, 5, 3, 0) }},
+			ticks: []kstreamdb.TickData{quietTick(),
+				testTick(2, 100, depth(99, 100), depth(100, 100)),
+				testTick(3, 105, depth(105, 100), depth(105.5, 100))},
+			check: func(t *testing.T, b *Book) {
+				target, _ := b.BracketLegs(1)
+				wantOrder(t, b, target, OrderStatusFilled, 10, 105)
+			},
+		},
+	})
+}
+
+func TestLatencyOnClose(t *testing.T) {
+	c := tickCase{
+		config: btConfig{latencyModel: FixedLatency(time.Hour)},
+		script: map[int]func(b *Book){0: func(b *Book) { b.Buy(10) }},
+		ticks:  []kstreamdb.TickData{quietTick(), testTick(1, 100, depth(99, 100), depth(100, 100))},
+	}
+	a := c.run()
+	wantOrder(t, &a.book, 1, OrderStatusOpen, 0, 0)
+	a.exit()
+	wantOrder(t, &a.book, 1, OrderStatusFilled, 10, 100)
+}
+
+func TestRandomLatency(t *testing.T) {
+	m := RandomLatency{Mean: 100 * time.Millisecond, StdDev: 50 * time.Millisecond, Min: 20 * time.Millisecond}
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		if d := m.Latency(Order{}, r); d < m.Min {
+			t.Fatalf("latency %v below the minimum", d)
+		}
+	}
+	if d := (RandomLatency{Mean: time.Millisecond, Min: 5 * time.Millisecond}).Latency(Order{}, r); d != 5*time.Millisecond {
+		t.Errorf("latency %v, want the minimum", d)
+	}
+}
+
+func TestInstanceRand(t *testing.T) {
+	a, b := newInstanceRand(7, "algo.SBIN"), newInstanceRand(7, "algo.SBIN")
+	other := newInstanceRand(7, "algo.INFY")
+	same, differ := true, false
+	for i := 0; i < 10; i++ {
+		x, y, z := a.Int63(), b.Int63(), other.Int63()
+		same = same && x == y
+		differ = differ || x != z
+	}
+	if !same {
+		t.Error("same seed and instance give different draws")
+	}
+	if !differ {
+		t.Error("instances share the draws")
+	}
+}
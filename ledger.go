@@ -6,13 +6,23 @@ import (
 )
 
 type orderEntry struct {
-	algoName string
-	symbol   string
-	at       time.Time
-	qty      int
-	price    float64
+	algoName   string
+	instanceID string
+	symbol     string
+	orderID    int
+	event      OrderEvent
+	placedAt   time.Time
+	at         time.Time
+	qty        int
+	price      float64
+	charges    float64
 }
 
 func (t orderEntry) String() string {
-	return fmt.Sprintf("%12s | %15s | %s | %4d | %9.2f", t.algoName, t.symbol, t.at.Format("2006/01/02 15:04:05"), t.qty, t.price)
+	return fmt.Sprintf("%12s | %15s | %5d | %9s | %s | %s | %4d | %9.2f | %7.2f", t.algoName, t.symbol, t.orderID, t.event, t.placedAt.Format("2006/01/02 15:04:05.000"), t.at.Format("2006/01/02 15:04:05.000"), t.qty, t.price, t.charges)
+}
+
+// isFill check, only the fills make up the trades
+func (t orderEntry) isFill() bool {
+	return t.event == OrderFilled || t.event == OrderPartiallyFilled
 }
@@ -0,0 +1,171 @@
+package malgova
+
+import (
+	"math"
+	"regexp"
+	"time"
+)
+
+// Segments of instruments
+const (
+	SegmentEquity  = "EQ"
+	SegmentFutures = "FUT"
+	SegmentOptions = "OPT"
+)
+
+var reOptionSymbol = regexp.MustCompile(`\d+(CE|PE)$`)
+
+// SegmentOf returns the segment of the NSE trading symbol
+func SegmentOf(symbol string) string {
+	if reOptionSymbol.MatchString(symbol) {
+		return SegmentOptions
+	}
+	if len(symbol) > 3 && symbol[len(symbol)-3:] == "FUT" {
+		return SegmentFutures
+	}
+	return SegmentEquity
+}
+
+// MarginModel struct, leverage is looked up by symbol first and then by
+// segment. Leverage of zero or not configured is taken as 1, ie full cash.
+type MarginModel struct {
+	SegmentLeverage map[string]float64
+	SymbolLeverage  map[string]float64
+	// ShortLeverage, if non-zero, is used for the short positions instead
+	ShortLeverage float64
+	// Intraday square-off time in hour and minute of the tick timezone,
+	// zero hour disables the square-off
+	SquareOffHour   int
+	SquareOffMinute int
+}
+
+// NewIntradayMargin returns a MIS margin model with the given equity
+// leverage, squared-off at 15:20
+func NewIntradayMargin(equityLeverage float64) *MarginModel {
+	return &MarginModel{
+		SegmentLeverage: map[string]float64{
+			SegmentEquity:  equityLeverage,
+			SegmentFutures: 1,
+			SegmentOptions: 1,
+		},
+		SymbolLeverage:  make(map[string]float64),
+		SquareOffHour:   15,
+		SquareOffMinute: 20,
+	}
+}
+
+// Leverage returns the leverage for the symbol
+func (m *MarginModel) Leverage(symbol string, short bool) float64 {
+	lev := 1.0
+	if l, ok := m.SymbolLeverage[symbol]; ok {
+		lev = l
+	} else if l, ok := m.SegmentLeverage[SegmentOf(symbol)]; ok {
+		lev = l
+	}
+	if short && m.ShortLeverage > 0 {
+		lev = m.ShortLeverage
+	}
+	if lev <= 0 {
+		return 1
+	}
+	return lev
+}
+
+// isSquareOffTime checks if the time is past the square-off time
+func (m *MarginModel) isSquareOffTime(t time.Time) bool {
+	if m.SquareOffHour == 0 {
+		return false
+	}
+	return t.Hour() > m.SquareOffHour || (t.Hour() == m.SquareOffHour && t.Minute() >= m.SquareOffMinute)
+}
+
+// leverage of the symbol
+func (b *Book) leverage(symbol string, short bool) float64 {
+	if b.Margin == nil {
+		return 1
+	}
+	return b.Margin.Leverage(symbol, short)
+}
+
+// marginRequired returns the funds blocked by the order
+func (b *Book) marginRequired(o *Order) float64 {
+	return float64(b.openingQuantity(o)) * b.orderValuePrice(o) / b.leverage(o.Symbol, o.Side == SideSell)
+}
+
+// Equity returns the cash plus the positions marked at the last price
+func (b *Book) Equity() float64 {
+	equity := b.Cash
+	for _, h := range b.holdings {
+		equity += float64(h.Position) * float64(b.quoteOf(h.Symbol).LastPrice)
+	}
+	return equity
+}
+
+// UsedMargin returns the funds blocked by the positions
+func (b *Book) UsedMargin() float64 {
+	used := 0.0
+	for _, h := range b.holdings {
+		value := math.Abs(float64(h.Position)) * float64(b.quoteOf(h.Symbol).LastPrice)
+		used += value / b.leverage(h.Symbol, h.Position < 0)
+	}
+	return used
+}
+
+// BlockedMargin returns the funds blocked by the working orders
+func (b *Book) BlockedMargin() float64 {
+	return b.blockedMargin(0)
+}
+
+// blockedMargin of the working orders, excluding the order with the given id
+func (b *Book) blockedMargin(excludeID int) float64 {
+	blocked := 0.0
+	for _, w := range b.working {
+		if w.IsOpen() && w.ID != excludeID {
+			blocked += b.marginRequired(w)
+		}
+	}
+	return blocked
+}
+
+// marginBySymbol returns the funds blocked by the position and the working
+// orders of each symbol
+func (b *Book) marginBySymbol() map[string]float64 {
+	used := make(map[string]float64)
+	for _, h := range b.holdings {
+		if h.Position != 0 {
+			value := math.Abs(float64(h.Position)) * float64(b.quoteOf(h.Symbol).LastPrice)
+			used[h.Symbol] += value / b.leverage(h.Symbol, h.Position < 0)
+		}
+	}
+	for _, w := range b.working {
+		if w.IsOpen() {
+			used[w.Symbol] += b.marginRequired(w)
+		}
+	}
+	return used
+}
+
+// freeCash returns the funds available for a new order, excluding the
+// order with the given id from the blocked funds
+func (b *Book) freeCash(excludeID int) float64 {
+	return b.Equity() - b.UsedMargin() - b.blockedMargin(excludeID)
+}
+
+// FreeCash returns the funds available for new orders
+func (b *Book) FreeCash() float64 {
+	return b.freeCash(0)
+}
+
+// squareOff exits the intraday position at the square-off time, once a day
+func (b *Book) squareOff(t time.Time) {
+	if b.Margin == nil || b.squaredOff || !b.Margin.isSquareOffTime(t) {
+		return
+	}
+	b.squaredOff = true
+	b.Exit()
+}
+
+// resetSquareOff for the new session
+func (b *Book) resetSquareOff() {
+	b.squaredOff = false
+}
@@ -0,0 +1,127 @@
+package malgova
+
+import (
+	"testing"
+
+	"github.com/sivamgr/kstreamdb"
+)
+
+func TestSegmentOf(t *testing.T) {
+	tests := map[string]string{
+		"SBIN":                  SegmentEquity,
+		"BAJAJ-AUTO":            SegmentEquity,
+		"NIFTY20JULFUT":         SegmentFutures,
+		"NIFTY20JUL11000CE":     SegmentOptions,
+		"BANKNIFTY2071622000PE": SegmentOptions,
+	}
+	for symbol, want := range tests {
+		if got := SegmentOf(symbol); got != want {
+			t.Errorf("%s: got %s, want %s", symbol, got, want)
+		}
+	}
+}
+
+func TestLeverage(t *testing.T) {
+	m := NewIntradayMargin(5)
+	m.SymbolLeverage["YESBANK"] = 2
+	tests := []struct {
+		symbol string
+		short  bool
+		want   float64
+	}{
+		{"SBIN", false, 5},
+		{"SBIN", true, 5},
+		{"YESBANK", false, 2},
+		{"NIFTY20JULFUT", false, 1},
+		{"NIFTY20JUL11000CE", false, 1},
+	}
+	for _, tt := range tests {
+		if got := m.Leverage(tt.symbol, tt.short); got != tt.want {
+			t.Errorf("%s short %v: got %.1f, want %.1f", tt.symbol, tt.short, got, tt.want)
+		}
+	}
+	m.ShortLeverage = 3
+	if got := m.Leverage("SBIN", true); got != 3 {
+		t.Errorf("short leverage %.1f, want 3", got)
+	}
+	if got := (&MarginModel{}).Leverage("SBIN", false); got != 1 {
+		t.Errorf("leverage without segments %.1f, want 1", got)
+	}
+}
+
+func TestIntradayMargin(t *testing.T) {
+	intraday := func(b *Book) {
+		b.AllocateCash(100000)
+		b.Margin = NewIntradayMargin(5)
+	}
+	fill := testTick(1, 100, depth(99.5, 10000), depth(100, 10000))
+	// 15:20 is the square-off time
+	squareOff := 6*3600 + 5*60
+	runTickCases(t, []tickCase{
+		{
+			name:   "leverage buys beyond the cash",
+			setup:  intraday,
+			script: map[int]func(b *Book){0: func(b *Book) { b.Buy(4000) }},
+			ticks:  []kstreamdb.TickData{quietTick(), fill},
+			check: func(t *testing.T, b *Book) {
+				wantOrder(t, b, 1, OrderStatusFilled, 4000, 100)
+				wantFloat(t, "used margin", b.UsedMargin(), 80000)
+				wantFloat(t, "free cash", b.FreeCash(), 20000)
+			},
+		},
+		{
+			name:   "leverage limits the order",
+			setup:  intraday,
+			script: map[int]func(b *Book){0: func(b *Book) { b.Buy(6000) }},
+			ticks:  []kstreamdb.TickData{quietTick()},
+			check: func(t *testing.T, b *Book) {
+				wantOrder(t, b, 1, OrderStatusRejected, 0, 0)
+			},
+		},
+		{
+			name:   "working orders block margin",
+			setup:  intraday,
+			script: map[int]func(b *Book){0: func(b *Book) { b.BuyLimit(2000, 99) }},
+			ticks:  []kstreamdb.TickData{quietTick()},
+			check: func(t *testing.T, b *Book) {
+				wantFloat(t, "blocked margin", b.BlockedMargin(), 2000*99/5.0)
+				if got := b.QuantityAffordable(100); got != int((100000-2000*99/5.0)*5/100) {
+					t.Errorf("quantity affordable %d", got)
+				}
+			},
+		},
+		{
+			name:  "position is squared-off at 15:20",
+			setup: intraday,
+			script: map[int]func(b *Book){
+				0: func(b *Book) { b.Buy(1000) },
+				3: func(b *Book) { b.Buy(10) },
+			},
+			ticks: []kstreamdb.TickData{quietTick(), fill,
+				testTick(squareOff-1, 100, depth(99.5, 10000), depth(100, 10000)),
+				testTick(squareOff, 101, depth(101, 10000), depth(101.5, 10000)),
+				testTick(squareOff+1, 101, depth(101, 10000), depth(101.5, 10000))},
+			check: func(t *testing.T, b *Book) {
+				wantOrder(t, b, 2, OrderStatusFilled, 1000, 101)
+				wantOrder(t, b, 3, OrderStatusRejected, 0, 0)
+				if b.Position != 0 {
+					t.Errorf("position %d, want 0", b.Position)
+				}
+				wantFloat(t, "realized pnl", b.RealizedPnl, 1000)
+			},
+		},
+		{
+			name:  "working orders are cancelled at the square-off",
+			setup: intraday,
+			script: map[int]func(b *Book){
+				0: func(b *Book) { b.BuyLimit(100, 90) },
+			},
+			ticks: []kstreamdb.TickData{quietTick(),
+				testTick(squareOff, 100, depth(99.5, 10000), depth(100, 10000))},
+			check: func(t *testing.T, b *Book) {
+				wantOrder(t, b, 1, OrderStatusCancelled, 0, 0)
+				wantFloat(t, "blocked margin", b.BlockedMargin(), 0)
+			},
+		},
+	})
+}
@@ -0,0 +1,175 @@
+package malgova
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+
+	"gonum.org/v1/gonum/stat"
+)
+
+// ResampleMethod of the monte carlo runs
+type ResampleMethod int
+
+// Resample methods
+const (
+	// ResampleShuffle reorders the trades, without replacement
+	ResampleShuffle ResampleMethod = iota
+	// ResampleBootstrap draws the trades with replacement
+	ResampleBootstrap
+	// ResampleBlockBootstrap draws blocks of consecutive trades with
+	// replacement, keeping the autocorrelation within the blocks
+	ResampleBlockBootstrap
+)
+
+func (m ResampleMethod) String() string {
+	switch m {
+	case ResampleShuffle:
+		return "SHUFFLE"
+	case ResampleBootstrap:
+		return "BOOTSTRAP"
+	case ResampleBlockBootstrap:
+		return "BLOCK"
+	}
+	return "UNKNOWN"
+}
+
+// MonteCarlo struct, the settings of the trade resampling
+type MonteCarlo struct {
+	Method    ResampleMethod
+	Runs      int     // resampled trade sequences, 1000 by default
+	BlockSize int     // trades per block, square root of the trades by default
+	Capital   float64 // starting equity
+	Seed      int64
+}
+
+// Distribution struct, the sorted samples of a statistic over the runs
+type Distribution struct {
+	Samples []float64
+}
+
+// Mean of the samples
+func (d Distribution) Mean() float64 {
+	if len(d.Samples) == 0 {
+		return 0
+	}
+	return stat.Mean(d.Samples, nil)
+}
+
+// Percentile of the samples, p in [0, 100]
+func (d Distribution) Percentile(p float64) float64 {
+	if len(d.Samples) == 0 {
+		return 0
+	}
+	return stat.Quantile(p/100, stat.Empirical, d.Samples, nil)
+}
+
+// ConfidenceInterval returns the percentile interval holding the confidence
+// level, e.g. 95 for the 2.5 and 97.5 percentiles
+func (d Distribution) ConfidenceInterval(level float64) (float64, float64) {
+	tail := (100 - level) / 2
+	return d.Percentile(tail), d.Percentile(100 - tail)
+}
+
+func (d Distribution) String() string {
+	lo, hi := d.ConfidenceInterval(90)
+	return fmt.Sprintf("%9.2f [%9.2f %9.2f]", d.Percentile(50), lo, hi)
+}
+
+func newDistribution(samples []float64) Distribution {
+	sort.Float64s(samples)
+	return Distribution{Samples: samples}
+}
+
+// MonteCarloResult struct, the distributions of the statistics of the algo
+// over the resampled trades
+type MonteCarloResult struct {
+	AlgoName    string
+	Symbol      string
+	Method      ResampleMethod
+	Trades      int
+	FinalEquity Distribution
+	MaxDrawdown Distribution
+	SQN         Distribution
+}
+
+// String reports the median with the 90% confidence interval
+func (r MonteCarloResult) String() string {
+	return fmt.Sprintf("%12s|%20s|%9s|%4d| %s | %s | %s", r.AlgoName, r.Symbol, r.Method, r.Trades, r.FinalEquity, r.MaxDrawdown, r.SQN)
+}
+
+// resample returns the trades drawn for one run
+func (mc MonteCarlo) resample(trades []tradeEntry, r *rand.Rand) []tradeEntry {
+	n := len(trades)
+	sample := make([]tradeEntry, 0, n)
+	switch mc.Method {
+	case ResampleBootstrap:
+		for i := 0; i < n; i++ {
+			sample = append(sample, trades[r.Intn(n)])
+		}
+	case ResampleBlockBootstrap:
+		block := mc.BlockSize
+		if block <= 0 {
+			block = int(math.Max(1, math.Round(math.Sqrt(float64(n)))))
+		}
+		// circular blocks, so the trades at the end are drawn as often
+		for len(sample) < n {
+			start := r.Intn(n)
+			for i := 0; i < block && len(sample) < n; i++ {
+				sample = append(sample, trades[(start+i)%n])
+			}
+		}
+	default:
+		for _, i := range r.Perm(n) {
+			sample = append(sample, trades[i])
+		}
+	}
+	return sample
+}
+
+// run resamples the trades and collects the statistics of every run
+func (mc MonteCarlo) run(t *tradeData) MonteCarloResult {
+	runs := mc.Runs
+	if runs <= 0 {
+		runs = 1000
+	}
+	r := rand.New(rand.NewSource(mc.Seed))
+	equity := make([]float64, 0, runs)
+	drawdown := make([]float64, 0, runs)
+	quality := make([]float64, 0, runs)
+	if len(t.trades) > 0 {
+		pnl := make([]float64, len(t.trades))
+		for i := 0; i < runs; i++ {
+			sample := mc.resample(t.trades, r)
+			final := mc.Capital
+			for j, trade := range sample {
+				final += trade.pnl
+				pnl[j] = trade.pnlPercentage
+			}
+			equity = append(equity, final)
+			drawdown = append(drawdown, maxDrawdown(sample))
+			quality = append(quality, sqn(pnl))
+		}
+	}
+	return MonteCarloResult{
+		AlgoName:    t.algoName,
+		Symbol:      t.symbol,
+		Method:      mc.Method,
+		Trades:      len(t.trades),
+		FinalEquity: newDistribution(equity),
+		MaxDrawdown: newDistribution(drawdown),
+		SQN:         newDistribution(quality),
+	}
+}
+
+// MonteCarlo resamples the trades of the last run, for every algo across the
+// symbols traded, with Symbol set to AllSymbols, ordered by algo name
+func (bt *BacktestEngine) MonteCarlo(mc MonteCarlo) []MonteCarloResult {
+	names, byAlgo := ordersByAlgo(bt.orders)
+	results := make([]MonteCarloResult, 0, len(names))
+	for _, name := range names {
+		results = append(results, mc.run(totalTrades(name, byAlgo[name])))
+	}
+	return results
+}
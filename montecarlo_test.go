@@ -0,0 +1,91 @@
+package malgova
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// tradesOf returns the trades of the pnl, the pnl percentage is the pnl
+func tradesOf(pnl ...float64) []tradeEntry {
+	trades := make([]tradeEntry, len(pnl))
+	for i, p := range pnl {
+		trades[i] = tradeEntry{pnl: p, grossPnl: p, pnlPercentage: p}
+	}
+	return trades
+}
+
+func TestResample(t *testing.T) {
+	trades := tradesOf(1, 2, 3, 4, 5, 6, 7, 8, 9)
+	indexOf := func(e tradeEntry) int { return int(e.pnl) - 1 }
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		shuffled := MonteCarlo{Method: ResampleShuffle}.resample(trades, r)
+		pnl := make([]float64, len(shuffled))
+		for j, e := range shuffled {
+			pnl[j] = e.pnl
+		}
+		sort.Float64s(pnl)
+		for j, p := range pnl {
+			if p != float64(j+1) {
+				t.Fatalf("shuffle is not a permutation: %v", pnl)
+			}
+		}
+
+		if drawn := (MonteCarlo{Method: ResampleBootstrap}).resample(trades, r); len(drawn) != len(trades) {
+			t.Fatalf("bootstrap drew %d trades", len(drawn))
+		}
+
+		blocks := MonteCarlo{Method: ResampleBlockBootstrap, BlockSize: 4}.resample(trades, r)
+		if len(blocks) != len(trades) {
+			t.Fatalf("block bootstrap drew %d trades", len(blocks))
+		}
+		for j := 1; j < len(blocks); j++ {
+			if j%4 != 0 && indexOf(blocks[j]) != (indexOf(blocks[j-1])+1)%len(trades) {
+				t.Fatalf("block broken at %d: %v", j, blocks)
+			}
+		}
+	}
+}
+
+func TestDistribution(t *testing.T) {
+	samples := make([]float64, 0, 100)
+	for i := 100; i >= 1; i-- {
+		samples = append(samples, float64(i))
+	}
+	d := newDistribution(samples)
+	wantFloat(t, "mean", d.Mean(), 50.5)
+	wantFloat(t, "median", d.Percentile(50), 50)
+	lo, hi := d.ConfidenceInterval(90)
+	wantFloat(t, "5th percentile", lo, 5)
+	wantFloat(t, "95th percentile", hi, 95)
+	empty := newDistribution(nil)
+	if empty.Mean() != 0 || empty.Percentile(50) != 0 {
+		t.Error("empty distribution is not zero")
+	}
+}
+
+func TestMonteCarloRun(t *testing.T) {
+	td := &tradeData{algoName: "momentum", symbol: AllSymbols, trades: tradesOf(100, -50, 200, -150, 75)}
+	res := MonteCarlo{Runs: 200, Capital: 10000, Seed: 1}.run(td)
+	if res.Trades != 5 || len(res.FinalEquity.Samples) != 200 {
+		t.Fatalf("trades %d runs %d", res.Trades, len(res.FinalEquity.Samples))
+	}
+	// the order of the trades does not change the final equity, only the path
+	if res.FinalEquity.Samples[0] != 10175 || res.FinalEquity.Samples[199] != 10175 {
+		t.Errorf("final equity %s, want 10175 in every run", res.FinalEquity)
+	}
+	if lo, hi := res.MaxDrawdown.Samples[0], res.MaxDrawdown.Samples[199]; lo != 150 || hi != 200 {
+		t.Errorf("max drawdown from %.0f to %.0f, want 150 to 200", lo, hi)
+	}
+	again := MonteCarlo{Runs: 200, Capital: 10000, Seed: 1, Method: ResampleBootstrap}.run(td)
+	same := MonteCarlo{Runs: 200, Capital: 10000, Seed: 1, Method: ResampleBootstrap}.run(td)
+	for i := range again.FinalEquity.Samples {
+		if again.FinalEquity.Samples[i] != same.FinalEquity.Samples[i] {
+			t.Fatal("runs of the seed differ")
+		}
+	}
+	if none := (MonteCarlo{}).run(&tradeData{}); none.Trades != 0 || len(none.SQN.Samples) != 0 {
+		t.Errorf("run without trades %+v", none)
+	}
+}
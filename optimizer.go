@@ -0,0 +1,262 @@
+package malgova
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sivamgr/kstreamdb"
+)
+
+// Params is a parameter set of the algo, by parameter name
+type Params map[string]float64
+
+// Int returns the parameter rounded to int
+func (p Params) Int(name string) int {
+	return int(math.Round(p[name]))
+}
+
+// Float returns the parameter
+func (p Params) Float(name string) float64 {
+	return p[name]
+}
+
+func (p Params) String() string {
+	names := make([]string, 0, len(p))
+	for name := range p {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	values := make([]string, 0, len(names))
+	for _, name := range names {
+		values = append(values, fmt.Sprintf("%s=%g", name, p[name]))
+	}
+	return "{" + strings.Join(values, ",") + "}"
+}
+
+// ParamRange struct, the values a parameter takes in the optimization
+type ParamRange struct {
+	Name   string
+	Values []float64
+}
+
+// Range returns the parameter range from min to max, both inclusive, in steps
+func Range(name string, min float64, max float64, step float64) ParamRange {
+	r := ParamRange{Name: name}
+	if step <= 0 {
+		return Choice(name, min)
+	}
+	for i := 0; ; i++ {
+		v := min + float64(i)*step
+		if v > max+step*1e-9 {
+			break
+		}
+		r.Values = append(r.Values, v)
+	}
+	return r
+}
+
+// Choice returns the parameter range of the values given
+func Choice(name string, values ...float64) ParamRange {
+	return ParamRange{Name: name, Values: values}
+}
+
+// Objective ranks the parameter sets by their score, higher is better
+type Objective func(s AlgoScore) float64
+
+// NetPnlObjective ranks by the net pnl
+func NetPnlObjective(s AlgoScore) float64 {
+	return s.NetPnl
+}
+
+// SQNObjective ranks by the system quality number
+func SQNObjective(s AlgoScore) float64 {
+	return s.SQN
+}
+
+// SharpeObjective ranks by the annualized sharpe ratio of the daily pnl
+func SharpeObjective(s AlgoScore) float64 {
+	return s.Sharpe
+}
+
+// RecoveryObjective ranks by the net pnl over the max drawdown, by the net
+// pnl when there is no drawdown
+func RecoveryObjective(s AlgoScore) float64 {
+	if s.MaxDrawdown <= 0 {
+		return s.NetPnl
+	}
+	return s.NetPnl / s.MaxDrawdown
+}
+
+// Optimizer struct, the algo factory and the parameter ranges to search.
+// Without a search strategy every combination of the ranges is backtested,
+// with one the search is limited to the budget of parameter sets, backtested
+// in batches.
+type Optimizer struct {
+	Name      string
+	Factory   func(p Params) AlgoStrategy
+	Ranges    []ParamRange
+	Objective Objective // NetPnlObjective by default
+
+	Search    SearchStrategy
+	Budget    int   // parameter sets to evaluate, 100 by default
+	BatchSize int   // parameter sets backtested together, 10 by default
+	Seed      int64 // of the search strategy
+}
+
+// OptimizeResult struct, the score of the parameter set across the symbols
+// traded
+type OptimizeResult struct {
+	Params    Params
+	Score     AlgoScore
+	Objective float64
+
+	daily map[string]float64 // net pnl by date, for the overfitting tests
+}
+
+func (r OptimizeResult) String() string {
+	return fmt.Sprintf("%9.3f | %s", r.Objective, r.Score)
+}
+
+// combinations returns every combination of the parameter ranges
+func (opt *Optimizer) combinations() []Params {
+	combos := []Params{{}}
+	for _, r := range opt.Ranges {
+		next := make([]Params, 0, len(combos)*len(r.Values))
+		for _, c := range combos {
+			for _, v := range r.Values {
+				p := make(Params, len(c)+1)
+				for name, cv := range c {
+					p[name] = cv
+				}
+				p[r.Name] = v
+				next = append(next, p)
+			}
+		}
+		combos = next
+	}
+	return combos
+}
+
+// objective returns the objective of the score
+func (opt *Optimizer) objective(s AlgoScore) float64 {
+	if opt.Objective == nil {
+		return NetPnlObjective(s)
+	}
+	return opt.Objective(s)
+}
+
+// Optimize runs the algo for every combination of the parameter ranges, or
+// the parameter sets picked by the search strategy, between the dates.
+// Returns the results ranked by the objective. Each day is loaded once for
+// all the parameter sets of a batch. The engine settings apply, except the
+// portfolio, every parameter set trades its own capital.
+func (bt *BacktestEngine) Optimize(feed *kstreamdb.DB, opt Optimizer, startDate time.Time, endDate time.Time) []OptimizeResult {
+	if opt.Search == nil {
+		return bt.evaluate(feed, &opt, opt.combinations(), startDate, endDate)
+	}
+	return bt.search(feed, &opt, startDate, endDate)
+}
+
+// search evaluates the parameter sets suggested by the search strategy in
+// batches, until the budget is spent. Sets suggested again are not
+// backtested again, but count against the budget.
+func (bt *BacktestEngine) search(feed *kstreamdb.DB, opt *Optimizer, startDate time.Time, endDate time.Time) []OptimizeResult {
+	budget := opt.Budget
+	if budget <= 0 {
+		budget = 100
+	}
+	batchSize := opt.BatchSize
+	if batchSize <= 0 {
+		batchSize = 10
+	}
+	r := rand.New(rand.NewSource(opt.Seed))
+	ranked := make([]OptimizeResult, 0, budget)
+	evaluated := make(map[string]bool)
+	for spent := 0; spent < budget; {
+		n := batchSize
+		if budget-spent < n {
+			n = budget - spent
+		}
+		suggested := opt.Search.Suggest(opt.Ranges, ranked, n, r)
+		if len(suggested) == 0 {
+			break
+		}
+		batch := make([]Params, 0, n)
+		for _, p := range suggested {
+			spent++
+			if key := p.String(); !evaluated[key] {
+				evaluated[key] = true
+				batch = append(batch, p)
+			}
+			if len(batch) == n {
+				break
+			}
+		}
+		if len(batch) == 0 {
+			if len(evaluated) >= opt.spaceSize() {
+				break
+			}
+			continue
+		}
+		ranked = append(ranked, bt.evaluate(feed, opt, batch, startDate, endDate)...)
+		rankResults(ranked)
+	}
+	return ranked
+}
+
+// spaceSize returns the number of combinations of the parameter ranges
+func (opt *Optimizer) spaceSize() int {
+	size := 1
+	for _, r := range opt.Ranges {
+		size *= len(r.Values)
+	}
+	return size
+}
+
+// evaluate runs the parameter sets in one pass over the days and ranks them
+func (bt *BacktestEngine) evaluate(feed *kstreamdb.DB, opt *Optimizer, sets []Params, startDate time.Time, endDate time.Time) []OptimizeResult {
+	e := BacktestEngine{config: bt.config}
+	e.config.portfolio = nil
+	e.config.universe = nil
+	names := make([]string, len(sets))
+	for i, p := range sets {
+		p := p
+		names[i] = opt.Name + p.String()
+		e.RegisterAlgoFactory(names[i], func() AlgoStrategy {
+			return opt.Factory(p)
+		})
+		if u, ok := bt.config.universe[opt.Name]; ok {
+			e.SetUniverse(names[i], u)
+		}
+	}
+	e.RunBetweenDate(feed, nil, startDate, endDate)
+
+	totals := make(map[string]*tradeData)
+	algoNames, byAlgo := ordersByAlgo(e.orders)
+	for _, name := range algoNames {
+		totals[name] = totalTrades(name, byAlgo[name])
+	}
+	results := make([]OptimizeResult, len(sets))
+	for i, p := range sets {
+		s := AlgoScore{AlgoName: names[i], Symbol: AllSymbols}
+		daily := make(map[string]float64)
+		if t, ok := totals[names[i]]; ok {
+			s = t.score
+			daily = dailyPnl(t.trades)
+		}
+		results[i] = OptimizeResult{Params: p, Score: s, Objective: opt.objective(s), daily: daily}
+	}
+	rankResults(results)
+	return results
+}
+
+// rankResults sorts the results by the objective, best first
+func rankResults(results []OptimizeResult) {
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Objective > results[j].Objective
+	})
+}
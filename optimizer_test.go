@@ -0,0 +1,79 @@
+package malgova
+
+import "testing"
+
+func TestRange(t *testing.T) {
+	tests := []struct {
+		name string
+		r    ParamRange
+		want []float64
+	}{
+		{"integer steps", Range("period", 10, 30, 10), []float64{10, 20, 30}},
+		{"max off the step", Range("period", 10, 35, 10), []float64{10, 20, 30}},
+		{"fractional steps reach the max", Range("stop", 0.1, 0.3, 0.1), []float64{0.1, 0.2, 0.3}},
+		{"no step", Range("period", 10, 30, 0), []float64{10}},
+		{"choice", Choice("mode", 1, 3, 2), []float64{1, 3, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if len(tt.r.Values) != len(tt.want) {
+				t.Fatalf("got %v, want %v", tt.r.Values, tt.want)
+			}
+			for i, v := range tt.want {
+				wantFloat(t, tt.r.Name, tt.r.Values[i], v)
+			}
+		})
+	}
+}
+
+func TestCombinations(t *testing.T) {
+	opt := Optimizer{Ranges: []ParamRange{
+		Range("fast", 5, 15, 5),
+		Range("slow", 20, 30, 10),
+		Choice("stop", 1),
+	}}
+	combos := opt.combinations()
+	if len(combos) != 6 || opt.spaceSize() != 6 {
+		t.Fatalf("%d combinations space %d, want 6", len(combos), opt.spaceSize())
+	}
+	seen := make(map[string]bool)
+	for _, c := range combos {
+		if len(c) != 3 || c["stop"] != 1 {
+			t.Errorf("combination %s", c)
+		}
+		seen[c.String()] = true
+	}
+	if len(seen) != 6 || !seen["{fast=10,slow=30,stop=1}"] {
+		t.Errorf("combinations %v", seen)
+	}
+	if got := (&Optimizer{}).combinations(); len(got) != 1 || len(got[0]) != 0 {
+		t.Errorf("combinations without ranges %v", got)
+	}
+}
+
+func TestParams(t *testing.T) {
+	p := Params{"period": 14.6, "stop": 0.5}
+	if p.Int("period") != 15 || p.Float("stop") != 0.5 || p.Int("missing") != 0 {
+		t.Errorf("period %d stop %g", p.Int("period"), p.Float("stop"))
+	}
+	if p.String() != "{period=14.6,stop=0.5}" {
+		t.Errorf("string %s", p)
+	}
+}
+
+func TestObjectives(t *testing.T) {
+	s := AlgoScore{NetPnl: 1000, MaxDrawdown: 250, SQN: 2, Sharpe: 1.5}
+	wantFloat(t, "net pnl", NetPnlObjective(s), 1000)
+	wantFloat(t, "sqn", SQNObjective(s), 2)
+	wantFloat(t, "sharpe", SharpeObjective(s), 1.5)
+	wantFloat(t, "recovery", RecoveryObjective(s), 4)
+	s.MaxDrawdown = 0
+	wantFloat(t, "recovery without drawdown", RecoveryObjective(s), 1000)
+	wantFloat(t, "default objective", (&Optimizer{}).objective(s), 1000)
+
+	results := []OptimizeResult{{Objective: 1}, {Objective: 3}, {Objective: 2}}
+	rankResults(results)
+	if results[0].Objective != 3 || results[2].Objective != 1 {
+		t.Errorf("ranked %v", results)
+	}
+}
@@ -0,0 +1,237 @@
+package malgova
+
+import (
+	"errors"
+	"time"
+)
+
+// Order errors
+var (
+	ErrOrderNotFound     = errors.New("order not found")
+	ErrOrderNotOpen      = errors.New("order is not open")
+	ErrInvalidQuantity   = errors.New("invalid quantity")
+	ErrInvalidPrice      = errors.New("invalid price")
+	ErrLotSize           = errors.New("quantity is not a multiple of lot size")
+	ErrCircuitLimit      = errors.New("price outside the circuit limit")
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	ErrSquareOffTime     = errors.New("intraday square-off time passed")
+	ErrPortfolioFunds    = errors.New("insufficient portfolio funds")
+	ErrAlgoLimit         = errors.New("algo funds limit exceeded")
+	ErrSymbolLimit       = errors.New("symbol funds limit exceeded")
+	ErrNoSymbol          = errors.New("order symbol not specified")
+	ErrTriggerCrossed    = errors.New("trigger price already crossed")
+	ErrNotInUniverse     = errors.New("symbol dropped from the universe")
+	ErrInvalidExpiry     = errors.New("good till time is not in the future")
+	ErrNoQuote           = errors.New("no price of the symbol to value the order")
+)
+
+// TimeInForce of an order
+type TimeInForce int
+
+// Time in force, DAY orders expire at the session close, IOC fills what is
+// available and cancels the rest, FOK fills fully or cancels, GTC persists
+// across sessions and GTT persists until the expiry time.
+const (
+	TIFDay TimeInForce = iota
+	TIFIOC
+	TIFFOK
+	TIFGTC
+	TIFGTT
+)
+
+// OrderSide of an order
+type OrderSide int
+
+// Order sides
+const (
+	SideBuy OrderSide = iota
+	SideSell
+)
+
+// OrderType of an order
+type OrderType int
+
+// Order types
+const (
+	OrderTypeMarket OrderType = iota
+	OrderTypeLimit
+	OrderTypeStopMarket
+	OrderTypeStopLimit
+)
+
+// OrderStatus of an order
+type OrderStatus int
+
+// Order status
+const (
+	OrderStatusOpen OrderStatus = iota
+	OrderStatusFilled
+	OrderStatusCancelled
+	OrderStatusRejected
+	OrderStatusExpired
+)
+
+// OrderEvent in the order lifecycle
+type OrderEvent int
+
+// Order events
+const (
+	OrderAccepted OrderEvent = iota
+	OrderPartiallyFilled
+	OrderFilled
+	OrderCancelled
+	OrderRejected
+	OrderModified
+	OrderExpired
+)
+
+// Order struct
+type Order struct {
+	ID             int
+	Symbol         string
+	Side           OrderSide
+	Type           OrderType
+	Quantity       int
+	FilledQuantity int
+	AvgFillPrice   float64
+	Price          float64
+	TriggerPrice   float64
+	Triggered      bool
+	QueueAhead     int
+	queued         bool
+	OCOGroup       int
+	ParentID       int
+	TimeInForce    TimeInForce
+	ExpireAt       time.Time
+	Status         OrderStatus
+	RejectReason   string
+	PlacedAt       time.Time
+	EligibleAt     time.Time
+	UpdatedAt      time.Time
+}
+
+// OrderUpdate struct, passed to OrderObserver on every order event
+type OrderUpdate struct {
+	Event        OrderEvent
+	Order        Order
+	At           time.Time
+	FillQuantity int
+	FillPrice    float64
+	Charges      float64
+}
+
+func (tif TimeInForce) String() string {
+	switch tif {
+	case TIFIOC:
+		return "IOC"
+	case TIFFOK:
+		return "FOK"
+	case TIFGTC:
+		return "GTC"
+	case TIFGTT:
+		return "GTT"
+	}
+	return "DAY"
+}
+
+func (s OrderSide) String() string {
+	if s == SideSell {
+		return "SELL"
+	}
+	return "BUY"
+}
+
+func (t OrderType) String() string {
+	switch t {
+	case OrderTypeLimit:
+		return "LIMIT"
+	case OrderTypeStopMarket:
+		return "SL-M"
+	case OrderTypeStopLimit:
+		return "SL"
+	}
+	return "MARKET"
+}
+
+func (s OrderStatus) String() string {
+	switch s {
+	case OrderStatusFilled:
+		return "FILLED"
+	case OrderStatusCancelled:
+		return "CANCELLED"
+	case OrderStatusRejected:
+		return "REJECTED"
+	case OrderStatusExpired:
+		return "EXPIRED"
+	}
+	return "OPEN"
+}
+
+func (e OrderEvent) String() string {
+	switch e {
+	case OrderPartiallyFilled:
+		return "PARTIAL"
+	case OrderFilled:
+		return "FILLED"
+	case OrderCancelled:
+		return "CANCELLED"
+	case OrderRejected:
+		return "REJECTED"
+	case OrderModified:
+		return "MODIFIED"
+	case OrderExpired:
+		return "EXPIRED"
+	}
+	return "ACCEPTED"
+}
+
+// IsOpen check
+func (o *Order) IsOpen() bool {
+	return o.Status == OrderStatusOpen
+}
+
+// IsStop check, true for stop orders yet to be triggered
+func (o *Order) IsStop() bool {
+	return (o.Type == OrderTypeStopMarket || o.Type == OrderTypeStopLimit) && !o.Triggered
+}
+
+// isExpired checks the order expiry at the time, DAY orders expire at the session close
+func (o *Order) isExpired(t time.Time, sessionClosed bool) bool {
+	switch o.TimeInForce {
+	case TIFDay:
+		return sessionClosed
+	case TIFGTT:
+		return !t.Before(o.ExpireAt)
+	}
+	return false
+}
+
+// isLimit check, true for limit and stop-limit orders
+func (o *Order) isLimit() bool {
+	return o.Type == OrderTypeLimit || o.Type == OrderTypeStopLimit
+}
+
+// isMarketable checks if the price satisfies the order, always true for
+// market orders
+func (o *Order) isMarketable(price float64) bool {
+	if !o.isLimit() {
+		return true
+	}
+	if o.Side == SideBuy {
+		return price <= o.Price
+	}
+	return price >= o.Price
+}
+
+// PendingQuantity returns the quantity yet to be filled
+func (o *Order) PendingQuantity() int {
+	return o.Quantity - o.FilledQuantity
+}
+
+// signed quantity, negative for sell orders
+func (o *Order) signed(qty int) int {
+	if o.Side == SideSell {
+		return -qty
+	}
+	return qty
+}
@@ -0,0 +1,228 @@
+package malgova
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"gonum.org/v1/gonum/stat"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+// eulerGamma is the Euler-Mascheroni constant, for the expected maximum
+// sharpe ratio of the trials
+const eulerGamma = 0.5772156649015329
+
+// OverfittingTest struct, the settings of the overfitting statistics of the
+// optimization trials. The statistics are on the daily net pnl of the
+// trials, over the days any trial closed trades.
+type OverfittingTest struct {
+	Partitions int     // CSCV partitions of the days, even, 10 by default
+	Confidence float64 // of the minimum track record length, 0.95 by default
+}
+
+// OverfittingReport struct, the significance of the best trial. Sharpe
+// ratios are annualized.
+type OverfittingReport struct {
+	Trials int
+	Days   int
+	// Sharpe of the best trial, the first of the ranked results
+	Sharpe float64
+	// ExpectedMaxSharpe of the trials, if none of them had any skill
+	ExpectedMaxSharpe float64
+	// DeflatedSharpe is the probability of the sharpe ratio of the best trial
+	// being above the expected maximum sharpe ratio of the trials
+	DeflatedSharpe float64
+	// PBO is the probability of backtest overfitting by combinatorially
+	// symmetric cross-validation, the chance of the best in-sample trial
+	// ranking below the median out-of-sample
+	PBO float64
+	// MinTrackRecord is the days needed for the sharpe ratio of the best
+	// trial to be above zero at the confidence level, infinite when the
+	// sharpe ratio is not positive
+	MinTrackRecord float64
+}
+
+func (r OverfittingReport) String() string {
+	return fmt.Sprintf("Trials %d | Days %d | Sharpe %7.3f | E[max Sharpe] %7.3f | DSR %5.3f | PBO %5.3f | MinTRL %7.1f", r.Trials, r.Days, r.Sharpe, r.ExpectedMaxSharpe, r.DeflatedSharpe, r.PBO, r.MinTrackRecord)
+}
+
+// Evaluate the ranked results of the optimization, all the trials run and
+// not only the best, as returned by Optimize
+func (o OverfittingTest) Evaluate(results []OptimizeResult) OverfittingReport {
+	report := OverfittingReport{Trials: len(results), MinTrackRecord: math.Inf(1)}
+	returns := trialReturns(results)
+	if len(results) == 0 || len(returns) < 2 {
+		return report
+	}
+	report.Days = len(returns)
+
+	sharpes := make([]float64, len(results))
+	for i := range results {
+		sharpes[i] = periodSharpe(returns, i)
+	}
+	best := column(returns, 0)
+	sr := sharpes[0]
+	skew := stat.Skew(best, nil)
+	kurtosis := stat.ExKurtosis(best, nil) + 3
+	if math.IsNaN(skew) || math.IsNaN(kurtosis) {
+		skew, kurtosis = 0, 3
+	}
+	// variance of the sharpe ratio estimate, non-normal returns
+	srVariance := 1 - skew*sr + (kurtosis-1)/4*sr*sr
+
+	sr0 := expectedMaxSharpe(sharpes)
+	report.Sharpe = sr * math.Sqrt(tradingDaysPerYear)
+	report.ExpectedMaxSharpe = sr0 * math.Sqrt(tradingDaysPerYear)
+	if srVariance > 0 {
+		z := (sr - sr0) * math.Sqrt(float64(report.Days-1)) / math.Sqrt(srVariance)
+		report.DeflatedSharpe = distuv.UnitNormal.CDF(z)
+		if sr > 0 {
+			confidence := o.Confidence
+			if confidence <= 0 || confidence >= 1 {
+				confidence = 0.95
+			}
+			za := distuv.UnitNormal.Quantile(confidence)
+			report.MinTrackRecord = 1 + srVariance*(za/sr)*(za/sr)
+		}
+	}
+	report.PBO = o.pbo(returns)
+	return report
+}
+
+// trialReturns returns the daily net pnl of the trials, a row per day with
+// a column per trial, in the order of the results
+func trialReturns(results []OptimizeResult) [][]float64 {
+	days := make(map[string]bool)
+	for _, r := range results {
+		for day := range r.daily {
+			days[day] = true
+		}
+	}
+	dates := make([]string, 0, len(days))
+	for day := range days {
+		dates = append(dates, day)
+	}
+	sort.Strings(dates)
+	returns := make([][]float64, len(dates))
+	for i, day := range dates {
+		returns[i] = make([]float64, len(results))
+		for j, r := range results {
+			returns[i][j] = r.daily[day]
+		}
+	}
+	return returns
+}
+
+func column(returns [][]float64, trial int) []float64 {
+	c := make([]float64, len(returns))
+	for i := range returns {
+		c[i] = returns[i][trial]
+	}
+	return c
+}
+
+// periodSharpe returns the daily sharpe ratio of the trial over the rows
+func periodSharpe(returns [][]float64, trial int) float64 {
+	return sharpeOf(column(returns, trial))
+}
+
+func sharpeOf(x []float64) float64 {
+	if len(x) < 2 {
+		return 0
+	}
+	mean, std := stat.MeanStdDev(x, nil)
+	if std == 0 || math.IsNaN(std) {
+		return 0
+	}
+	return mean / std
+}
+
+// expectedMaxSharpe returns the expected maximum of the sharpe ratios of
+// the trials, under the null hypothesis of no skill
+func expectedMaxSharpe(sharpes []float64) float64 {
+	n := float64(len(sharpes))
+	if n < 2 {
+		return 0
+	}
+	std := stat.StdDev(sharpes, nil)
+	return std * ((1-eulerGamma)*distuv.UnitNormal.Quantile(1-1/n) + eulerGamma*distuv.UnitNormal.Quantile(1-1/(n*math.E)))
+}
+
+// pbo estimates the probability of backtest overfitting. The days are split
+// into partitions, every half of the partitions is in-sample once, with the
+// rest out-of-sample. Returns the share of the splits where the best trial
+// in-sample ranks at or below the median out-of-sample.
+func (o OverfittingTest) pbo(returns [][]float64) float64 {
+	partitions := o.Partitions
+	if partitions <= 0 {
+		partitions = 10
+	}
+	partitions -= partitions % 2
+	if len(returns) < partitions {
+		partitions = len(returns) - len(returns)%2
+	}
+	trials := len(returns[0])
+	if partitions < 2 || trials < 2 {
+		return 0
+	}
+	// rows of the partitions
+	bounds := make([]int, partitions+1)
+	for i := range bounds {
+		bounds[i] = i * len(returns) / partitions
+	}
+	rowsOf := func(parts []bool, inSample bool) [][]float64 {
+		rows := make([][]float64, 0)
+		for p, in := range parts {
+			if in == inSample {
+				rows = append(rows, returns[bounds[p]:bounds[p+1]]...)
+			}
+		}
+		return rows
+	}
+
+	overfit := 0
+	splits := 0
+	choosePartitions(partitions, partitions/2, func(parts []bool) {
+		is, oos := rowsOf(parts, true), rowsOf(parts, false)
+		best := 0
+		bestSharpe := math.Inf(-1)
+		for t := 0; t < trials; t++ {
+			if sr := periodSharpe(is, t); sr > bestSharpe {
+				best, bestSharpe = t, sr
+			}
+		}
+		oosBest := periodSharpe(oos, best)
+		rank := 0
+		for t := 0; t < trials; t++ {
+			if periodSharpe(oos, t) <= oosBest {
+				rank++
+			}
+		}
+		// relative rank out-of-sample, the logit is not positive at or below the median
+		w := float64(rank) / float64(trials+1)
+		if math.Log(w/(1-w)) <= 0 {
+			overfit++
+		}
+		splits++
+	})
+	return float64(overfit) / float64(splits)
+}
+
+// choosePartitions calls fn with every choice of k of the n partitions
+func choosePartitions(n int, k int, fn func(chosen []bool)) {
+	chosen := make([]bool, n)
+	var choose func(from int, left int)
+	choose = func(from int, left int) {
+		if left == 0 {
+			fn(chosen)
+			return
+		}
+		for i := from; i <= n-left; i++ {
+			chosen[i] = true
+			choose(i+1, left-1)
+			chosen[i] = false
+		}
+	}
+	choose(0, k)
+}
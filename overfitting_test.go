@@ -0,0 +1,103 @@
+package malgova
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+// trialsOf returns the results of the trials from their daily net pnl
+func trialsOf(daily ...[]float64) []OptimizeResult {
+	results := make([]OptimizeResult, len(daily))
+	for i, pnl := range daily {
+		results[i].daily = make(map[string]float64)
+		for day, p := range pnl {
+			results[i].daily[fmt.Sprintf("2020/07/%02d", day+1)] = p
+		}
+	}
+	return results
+}
+
+func TestSharpeOf(t *testing.T) {
+	wantFloat(t, "sharpe", sharpeOf([]float64{1, 2, 3, 4, 5}), 1.8973665961010275)
+	wantFloat(t, "sharpe of one day", sharpeOf([]float64{1}), 0)
+	wantFloat(t, "sharpe without variance", sharpeOf([]float64{2, 2, 2}), 0)
+}
+
+func TestExpectedMaxSharpe(t *testing.T) {
+	// 100 trials of unit variance, as in the deflated sharpe ratio paper
+	sharpes := make([]float64, 100)
+	for i := range sharpes {
+		sharpes[i] = float64(i)
+	}
+	std := math.Sqrt(float64(100*101) / 12)
+	wantFloat(t, "expected max sharpe", expectedMaxSharpe(sharpes)/std, 2.5306028932016846)
+	wantFloat(t, "one trial", expectedMaxSharpe([]float64{1.5}), 0)
+}
+
+func TestChoosePartitions(t *testing.T) {
+	seen := make(map[string]bool)
+	choosePartitions(6, 3, func(chosen []bool) {
+		n := 0
+		for _, c := range chosen {
+			if c {
+				n++
+			}
+		}
+		if n != 3 {
+			t.Errorf("%v chooses %d", chosen, n)
+		}
+		seen[fmt.Sprint(chosen)] = true
+	})
+	if len(seen) != 20 {
+		t.Errorf("%d choices, want C(6,3) = 20", len(seen))
+	}
+}
+
+func TestPBO(t *testing.T) {
+	tests := []struct {
+		name    string
+		returns [][]float64
+		want    float64
+	}{
+		{
+			// the best trial of each half is the worst of the other
+			name:    "overfit",
+			returns: [][]float64{{1, -1}, {2, -2}, {-1, 1}, {-2, 2}},
+			want:    1,
+		},
+		{
+			name:    "consistent",
+			returns: [][]float64{{1, -1}, {2, -2}, {1.5, -1}, {2.5, -2}},
+			want:    0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wantFloat(t, "pbo", OverfittingTest{Partitions: 2}.pbo(tt.returns), tt.want)
+		})
+	}
+}
+
+func TestOverfittingEvaluate(t *testing.T) {
+	results := trialsOf(
+		[]float64{1, 2, -1, 3, 2, 1},
+		[]float64{0.5, -1, 1, -0.5, 1, 0},
+	)
+	r := OverfittingTest{}.Evaluate(results)
+	if r.Trials != 2 || r.Days != 6 {
+		t.Fatalf("trials %d days %d", r.Trials, r.Days)
+	}
+	wantFloat(t, "sharpe", r.Sharpe, 15.491933384829668)
+	wantFloat(t, "expected max sharpe", r.ExpectedMaxSharpe, 4.502725424915074)
+	wantFloat(t, "deflated sharpe", r.DeflatedSharpe, 0.8286046969702006)
+	wantFloat(t, "min track record", r.MinTrackRecord, 8.563443352475648)
+
+	losing := OverfittingTest{}.Evaluate(trialsOf([]float64{-1, -2, 1}, []float64{1, 2, 3}))
+	if !math.IsInf(losing.MinTrackRecord, 1) {
+		t.Errorf("min track record of a losing trial %.2f", losing.MinTrackRecord)
+	}
+	if empty := (OverfittingTest{}).Evaluate(nil); empty.Days != 0 || !math.IsInf(empty.MinTrackRecord, 1) {
+		t.Errorf("report without trials %+v", empty)
+	}
+}
@@ -0,0 +1,186 @@
+package malgova
+
+import (
+	"sync"
+	"time"
+)
+
+// Portfolio struct, one capital pool shared by all the algo instances of the
+// engine. Orders are checked against the free funds of the pool and the
+// limits per algo and per symbol, on top of the cash of the book. The funds
+// used by an instance are the margin blocked by its position and working
+// orders. Instances run concurrently within a day, the funds of an order are
+// reserved as it is checked, so the pool is never overspent, though which
+// instance gets the last funds depends on the goroutine scheduling.
+type Portfolio struct {
+	Capital float64
+
+	mu          sync.Mutex
+	algoLimit   map[string]float64
+	symbolLimit map[string]float64
+	instances   map[string]*portfolioUsage
+	equity      []EquityPoint
+}
+
+// EquityPoint struct, the portfolio equity at the end of the day
+type EquityPoint struct {
+	Date   time.Time
+	Equity float64
+	Used   float64
+}
+
+// portfolioUsage of an instance, the funds used are tracked by the symbol of
+// the orders, as portfolio strategies trade many symbols
+type portfolioUsage struct {
+	algoName string
+	used     float64
+	bySymbol map[string]float64
+	pnl      float64
+}
+
+// NewPortfolio creates a portfolio with the capital
+func NewPortfolio(capital float64) *Portfolio {
+	return &Portfolio{
+		Capital:     capital,
+		algoLimit:   make(map[string]float64),
+		symbolLimit: make(map[string]float64),
+		instances:   make(map[string]*portfolioUsage),
+	}
+}
+
+// SetAlgoLimit caps the funds used by all the instances of the algo
+func (p *Portfolio) SetAlgoLimit(algoName string, amount float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.algoLimit[algoName] = amount
+}
+
+// SetSymbolLimit caps the funds used by the orders of the symbol across algos
+func (p *Portfolio) SetSymbolLimit(symbol string, amount float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.symbolLimit[symbol] = amount
+}
+
+// EquityCurve returns the portfolio equity by day
+func (p *Portfolio) EquityCurve() []EquityPoint {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]EquityPoint(nil), p.equity...)
+}
+
+// Equity returns the capital plus the pnl of all the instances
+func (p *Portfolio) Equity() float64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	equity, _ := p.totals()
+	return equity
+}
+
+// FreeFunds returns the funds of the pool not used by any instance
+func (p *Portfolio) FreeFunds() float64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	equity, used := p.totals()
+	return equity - used
+}
+
+func (p *Portfolio) totals() (float64, float64) {
+	equity := p.Capital
+	used := 0.0
+	for _, u := range p.instances {
+		equity += u.pnl
+		used += u.used
+	}
+	return equity, used
+}
+
+// allocation returns the cash for a book, which did not allocate cash on setup
+func (p *Portfolio) allocation(algoName string, symbol string) float64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	cash := p.Capital
+	if l, ok := p.algoLimit[algoName]; ok && l < cash {
+		cash = l
+	}
+	if l, ok := p.symbolLimit[symbol]; ok && l < cash {
+		cash = l
+	}
+	return cash
+}
+
+// attach registers the book with the portfolio
+func (p *Portfolio) attach(b *Book, instanceID string, algoName string) {
+	if b.CashAllocated == 0 {
+		b.AllocateCash(p.allocation(algoName, b.symbol))
+	}
+	b.portfolio = p
+	b.instanceID = instanceID
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.instances[instanceID] = &portfolioUsage{
+		algoName: algoName,
+		bySymbol: make(map[string]float64),
+	}
+}
+
+// update refreshes the funds used and the pnl of the book
+func (p *Portfolio) update(b *Book) {
+	bySymbol := b.marginBySymbol()
+	used := 0.0
+	for _, m := range bySymbol {
+		used += m
+	}
+	pnl := b.Equity() - b.CashAllocated
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if u, ok := p.instances[b.instanceID]; ok {
+		u.used = used
+		u.bySymbol = bySymbol
+		u.pnl = pnl
+	}
+}
+
+// check the additional funds of the order in the symbol for the instance
+// against the pool and limits, and reserves them for the instance until its
+// next update
+func (p *Portfolio) check(instanceID string, symbol string, required float64) error {
+	if required <= 0 {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	inst, ok := p.instances[instanceID]
+	if !ok {
+		return nil
+	}
+	equity, used := p.totals()
+	if used+required > equity {
+		return ErrPortfolioFunds
+	}
+	algoUsed := 0.0
+	symbolUsed := 0.0
+	for _, u := range p.instances {
+		if u.algoName == inst.algoName {
+			algoUsed += u.used
+		}
+		symbolUsed += u.bySymbol[symbol]
+	}
+	if l, ok := p.algoLimit[inst.algoName]; ok && algoUsed+required > l {
+		return ErrAlgoLimit
+	}
+	if l, ok := p.symbolLimit[symbol]; ok && symbolUsed+required > l {
+		return ErrSymbolLimit
+	}
+	inst.used += required
+	inst.bySymbol[symbol] += required
+	return nil
+}
+
+// recordEquity appends the end of day equity to the curve
+func (p *Portfolio) recordEquity(dt time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	equity, used := p.totals()
+	p.equity = append(p.equity, EquityPoint{Date: dt, Equity: equity, Used: used})
+}
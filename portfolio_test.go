@@ -0,0 +1,226 @@
+package malgova
+
+import (
+	"testing"
+
+	"github.com/sivamgr/kstreamdb"
+)
+
+// attachBook attaches a book of the symbol to the portfolio
+func attachBook(p *Portfolio, instanceID string, algoName string, symbol string) *Book {
+	b := &Book{symbol: symbol}
+	b.AllocateCash(p.Capital)
+	p.attach(b, instanceID, algoName)
+	return b
+}
+
+func TestPortfolioCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		limits func(p *Portfolio)
+		first  float64
+		second float64
+		want   error
+	}{
+		{name: "within the pool", first: 60000, second: 40000},
+		{name: "reserved funds are used", first: 60000, second: 40001, want: ErrPortfolioFunds},
+		{
+			name:   "algo limit",
+			limits: func(p *Portfolio) { p.SetAlgoLimit("momentum", 70000) },
+			first:  60000,
+			second: 10001,
+			want:   ErrAlgoLimit,
+		},
+		{
+			name:   "symbol limit",
+			limits: func(p *Portfolio) { p.SetSymbolLimit("SBIN", 50000) },
+			first:  30000,
+			second: 20001,
+			want:   ErrSymbolLimit,
+		},
+		{
+			name:   "limits of other algos and symbols",
+			limits: func(p *Portfolio) { p.SetAlgoLimit("reversal", 10000); p.SetSymbolLimit("INFY", 10000) },
+			first:  60000,
+			second: 40000,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPortfolio(100000)
+			if tt.limits != nil {
+				tt.limits(p)
+			}
+			attachBook(p, "momentum::SBIN", "momentum", "SBIN")
+			attachBook(p, "momentum::SBIN#2", "momentum", "SBIN")
+			if err := p.check("momentum::SBIN", "SBIN", tt.first); err != nil {
+				t.Fatalf("first check %v", err)
+			}
+			if err := p.check("momentum::SBIN#2", "SBIN", tt.second); err != tt.want {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPortfolioUpdate(t *testing.T) {
+	p := NewPortfolio(100000)
+	b := attachBook(p, "momentum::SBIN", "momentum", "SBIN")
+	b.setClock(testStart)
+	b.updateQuote(quietTick())
+	if err := p.check("momentum::SBIN", "SBIN", 90000); err != nil {
+		t.Fatal(err)
+	}
+	wantFloat(t, "reserved free funds", p.FreeFunds(), 10000)
+
+	// the update replaces the reservation with the funds used by the book
+	b.applyFill(testSymbol, 100, 100, 0)
+	p.update(b)
+	wantFloat(t, "free funds", p.FreeFunds(), 100000-100*100)
+
+	t2 := quietTick()
+	t2.LastPrice = 110
+	b.updateQuote(t2)
+	p.update(b)
+	wantFloat(t, "equity", p.Equity(), 101000)
+	p.recordEquity(testStart)
+	if curve := p.EquityCurve(); len(curve) != 1 || curve[0].Equity != 101000 || curve[0].Used != 11000 {
+		t.Errorf("equity curve %+v", curve)
+	}
+}
+
+func TestPortfolioAllocation(t *testing.T) {
+	p := NewPortfolio(100000)
+	p.SetAlgoLimit("momentum", 50000)
+	p.SetSymbolLimit("SBIN", 20000)
+	tests := []struct {
+		algoName string
+		symbol   string
+		want     float64
+	}{
+		{"momentum", "SBIN", 20000},
+		{"momentum", "INFY", 50000},
+		{"reversal", "INFY", 100000},
+	}
+	for _, tt := range tests {
+		b := &Book{symbol: tt.symbol}
+		p.attach(b, tt.algoName+"::"+tt.symbol, tt.algoName)
+		wantFloat(t, tt.algoName+" "+tt.symbol, b.CashAllocated, tt.want)
+	}
+}
+
+func TestPortfolioOrders(t *testing.T) {
+	var modifyErr error
+	modify := func(qty int, price float64) map[int]func(b *Book) {
+		return map[int]func(b *Book){
+			0: func(b *Book) { b.BuyLimit(900, 95) },
+			1: func(b *Book) { modifyErr = b.Modify(1, qty, price) },
+		}
+	}
+	ticks := []kstreamdb.TickData{quietTick(), testTick(1, 100, depth(99.5, 1000), depth(100.5, 1000))}
+	runTickCases(t, []tickCase{
+		{
+			name:   "order beyond the pool is rejected",
+			config: btConfig{portfolio: NewPortfolio(100000)},
+			script: map[int]func(b *Book){0: func(b *Book) { b.Buy(1000) }},
+			ticks:  ticks[:1],
+			check: func(t *testing.T, b *Book) {
+				if o := order(t, b, 1); o.RejectReason != ErrPortfolioFunds.Error() {
+					t.Errorf("%s %q, want rejected for the portfolio funds", o.Status, o.RejectReason)
+				}
+			},
+		},
+		{
+			name:   "modify needs only the extra funds",
+			config: btConfig{portfolio: NewPortfolio(100000)},
+			script: modify(0, 95.5),
+			ticks:  ticks,
+			check: func(t *testing.T, b *Book) {
+				if modifyErr != nil {
+					t.Fatal(modifyErr)
+				}
+				if o := order(t, b, 1); o.Price != 95.5 {
+					t.Errorf("price %.2f, want 95.5", o.Price)
+				}
+				wantFloat(t, "free funds", b.portfolio.FreeFunds(), 100000-900*95.5)
+			},
+		},
+		{
+			name:   "modify beyond the pool keeps the order",
+			config: btConfig{portfolio: NewPortfolio(100000)},
+			script: modify(1100, 0),
+			ticks:  ticks,
+			check: func(t *testing.T, b *Book) {
+				if modifyErr != ErrPortfolioFunds {
+					t.Errorf("got %v, want %v", modifyErr, ErrPortfolioFunds)
+				}
+				if o := order(t, b, 1); !o.IsOpen() || o.Quantity != 900 {
+					t.Errorf("%s quantity %d, want open 900", o.Status, o.Quantity)
+				}
+			},
+		},
+	})
+}
+
+func TestPortfolioSymbolLimit(t *testing.T) {
+	p := NewPortfolio(100000)
+	p.SetSymbolLimit("INFY", 20000)
+	attachBook(p, "momentum::SBIN", "momentum", "SBIN")
+	rotation := attachBook(p, "rotation::PORTFOLIO", "rotation", "")
+	if err := p.check("rotation::PORTFOLIO", "INFY", 20001); err != ErrSymbolLimit {
+		t.Errorf("portfolio strategy: got %v, want %v", err, ErrSymbolLimit)
+	}
+	if err := p.check("momentum::SBIN", "INFY", 15000); err != nil {
+		t.Fatalf("hedge of the instance: %v", err)
+	}
+	if err := p.check("rotation::PORTFOLIO", "INFY", 6000); err != ErrSymbolLimit {
+		t.Errorf("limit across instances: got %v, want %v", err, ErrSymbolLimit)
+	}
+	if err := p.check("rotation::PORTFOLIO", "SBIN", 50000); err != nil {
+		t.Errorf("symbol without a limit: %v", err)
+	}
+
+	// the update tracks the funds by the symbol of the position
+	rotation.setClock(testStart)
+	rotation.updateQuote(of("INFY", quietTick()))
+	rotation.applyFill("INFY", 50, 100, 0)
+	p.update(rotation)
+	if err := p.check("rotation::PORTFOLIO", "INFY", 0.01); err != ErrSymbolLimit {
+		t.Errorf("position of the symbol: got %v, want %v", err, ErrSymbolLimit)
+	}
+}
+
+func TestPortfolioSymbolLimitOfOrders(t *testing.T) {
+	limited := func() *Portfolio {
+		p := NewPortfolio(1000000)
+		p.SetSymbolLimit("INFY", 20000)
+		return p
+	}
+	ticks := []kstreamdb.TickData{of("INFY", quietTick()), quietTick()}
+	runTickCases(t, []tickCase{
+		{
+			name:   "order of the limited symbol",
+			config: btConfig{portfolio: limited()},
+			watch:  []string{"INFY"},
+			script: map[int]func(b *Book){1: func(b *Book) { b.BuySymbol("INFY", 300) }},
+			ticks:  ticks,
+			check: func(t *testing.T, b *Book) {
+				if o := order(t, b, 1); o.RejectReason != ErrSymbolLimit.Error() {
+					t.Errorf("%s %q, want rejected for the symbol limit", o.Status, o.RejectReason)
+				}
+			},
+		},
+		{
+			name:   "order of the instance symbol",
+			config: btConfig{portfolio: limited()},
+			watch:  []string{"INFY"},
+			script: map[int]func(b *Book){1: func(b *Book) { b.Buy(300) }},
+			ticks:  ticks,
+			check: func(t *testing.T, b *Book) {
+				if o := order(t, b, 1); o.Status == OrderStatusRejected {
+					t.Errorf("rejected: %s", o.RejectReason)
+				}
+			},
+		},
+	})
+}
@@ -0,0 +1,63 @@
+package malgova
+
+// joinQueue fills the limit order against the opposite depth if marketable on
+// arrival, otherwise places it behind the quantity displayed at its price
+func (a *btAlgoRunner) joinQueue(o *Order) {
+	o.queued = true
+	if qty, price := walkDepth(o, a.lastTick); qty > 0 {
+		a.fillOrder(o, qty, price)
+		if !o.IsOpen() {
+			return
+		}
+	}
+	o.QueueAhead = a.displayedQuantity(o)
+}
+
+// displayedQuantity returns the quantity displayed on the own side of the
+// depth at the order price, zero when the level is not visible
+func (a *btAlgoRunner) displayedQuantity(o *Order) int {
+	levels := a.lastTick.Bid
+	if o.Side == SideSell {
+		levels = a.lastTick.Ask
+	}
+	for _, l := range levels {
+		if l.Price == float32(o.Price) {
+			return int(l.Quantity)
+		}
+	}
+	return 0
+}
+
+// matchQueued fills the queued limit order, once the volume traded at its
+// price has consumed the queue ahead of it. A trade through the price fills
+// the order in full.
+func (a *btAlgoRunner) matchQueued(o *Order) {
+	if !o.queued {
+		a.joinQueue(o)
+		return
+	}
+
+	ltp := a.lastTick.LastPrice
+	if ltp != float32(o.Price) {
+		if o.isMarketable(float64(ltp)) {
+			a.fillOrder(o, o.PendingQuantity(), o.Price)
+		}
+		return
+	}
+
+	// cancellations ahead of the order shrink the queue
+	if displayed := a.displayedQuantity(o); displayed < o.QueueAhead {
+		o.QueueAhead = displayed
+	}
+	traded := a.volumeTraded
+	if traded <= o.QueueAhead {
+		o.QueueAhead -= traded
+		return
+	}
+	traded -= o.QueueAhead
+	o.QueueAhead = 0
+	if traded > o.PendingQuantity() {
+		traded = o.PendingQuantity()
+	}
+	a.fillOrder(o, traded, o.Price)
+}
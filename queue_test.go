@@ -0,0 +1,98 @@
+package malgova
+
+import (
+	"testing"
+
+	"github.com/sivamgr/kstreamdb"
+)
+
+func TestQueuePosition(t *testing.T) {
+	queued := btConfig{queuePosition: true}
+	book := func(sec int, ltp float32, bidQty float32, volume uint32) kstreamdb.TickData {
+		return traded(testTick(sec, ltp, depth(99.5, bidQty), depth(100, 100)), volume)
+	}
+	join := map[int]func(b *Book){0: func(b *Book) { b.BuyLimit(10, 99.5) }}
+	runTickCases(t, []tickCase{
+		{
+			name:   "order joins behind the displayed quantity",
+			config: queued,
+			script: join,
+			ticks:  []kstreamdb.TickData{book(0, 100, 50, 1000), book(1, 100, 50, 1000)},
+			check: func(t *testing.T, b *Book) {
+				if o := b.orders[1]; !o.queued || o.QueueAhead != 50 {
+					t.Errorf("queued %v ahead %d, want behind 50", o.queued, o.QueueAhead)
+				}
+			},
+		},
+		{
+			name:   "traded volume consumes the queue ahead",
+			config: queued,
+			script: join,
+			ticks:  []kstreamdb.TickData{book(0, 100, 50, 1000), book(1, 100, 50, 1000), book(2, 99.5, 50, 1030)},
+			check: func(t *testing.T, b *Book) {
+				wantOrder(t, b, 1, OrderStatusOpen, 0, 0)
+				if o := b.orders[1]; o.QueueAhead != 20 {
+					t.Errorf("ahead %d, want 20", o.QueueAhead)
+				}
+			},
+		},
+		{
+			name:   "volume beyond the queue fills the order",
+			config: queued,
+			script: join,
+			ticks: []kstreamdb.TickData{book(0, 100, 50, 1000), book(1, 100, 50, 1000),
+				book(2, 99.5, 50, 1030), book(3, 99.5, 50, 1055)},
+			check: func(t *testing.T, b *Book) {
+				wantOrder(t, b, 1, OrderStatusOpen, 5, 99.5)
+			},
+		},
+		{
+			name:   "trade through the price fills the order",
+			config: queued,
+			script: join,
+			ticks: []kstreamdb.TickData{book(0, 100, 50, 1000), book(1, 100, 50, 1000),
+				book(2, 99, 50, 1100)},
+			check: func(t *testing.T, b *Book) {
+				wantOrder(t, b, 1, OrderStatusFilled, 10, 99.5)
+			},
+		},
+		{
+			name:   "cancellations ahead shrink the queue",
+			config: queued,
+			script: join,
+			ticks:  []kstreamdb.TickData{book(0, 100, 50, 1000), book(1, 100, 50, 1000), book(2, 99.5, 15, 1010)},
+			check: func(t *testing.T, b *Book) {
+				if o := b.orders[1]; o.QueueAhead != 5 {
+					t.Errorf("ahead %d, want 5", o.QueueAhead)
+				}
+			},
+		},
+		{
+			name:   "modified price goes to the back of the queue",
+			config: queued,
+			script: map[int]func(b *Book){
+				0: func(b *Book) { b.BuyLimit(10, 100) },
+				1: func(b *Book) { b.Modify(1, 0, 99.5) },
+			},
+			ticks: []kstreamdb.TickData{
+				traded(testTick(0, 100, depth(100, 50), depth(100.5, 50)), 1000),
+				traded(testTick(1, 100, depth(100, 50), depth(100.5, 50)), 1000)},
+			check: func(t *testing.T, b *Book) {
+				if o := b.orders[1]; o.queued || o.QueueAhead != 0 {
+					t.Errorf("queued %v ahead %d, want a fresh queue position", o.queued, o.QueueAhead)
+				}
+			},
+		},
+		{
+			name:   "reduced quantity keeps the queue position",
+			config: queued,
+			script: map[int]func(b *Book){1: func(b *Book) { b.BuyLimit(10, 99.5) }, 2: func(b *Book) { b.Modify(1, 5, 0) }},
+			ticks:  []kstreamdb.TickData{book(0, 100, 50, 1000), book(1, 100, 50, 1000), book(2, 100, 50, 1000)},
+			check: func(t *testing.T, b *Book) {
+				if o := b.orders[1]; !o.queued || o.QueueAhead != 50 || o.Quantity != 5 {
+					t.Errorf("queued %v ahead %d quantity %d, want 5 behind 50", o.queued, o.QueueAhead, o.Quantity)
+				}
+			},
+		},
+	})
+}
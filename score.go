@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"math"
 	"sort"
+	"time"
 
 	"gonum.org/v1/gonum/stat"
 )
@@ -22,26 +23,36 @@ type AlgoScore struct {
 	Symbol   string
 	// stats and scores
 	OrdersCount          int
+	OrdersCancelled      int
+	OrdersModified       int
+	OrdersRejected       int
 	TradesCount          int
 	TradesWon            int
 	TradesLost           int
 	WinStreak            int
 	LossStreak           int
+	GrossPnl             float64
+	Charges              float64
 	NetPnl               float64
 	NetPnlPercentAverage float64
 	NetPnlPercentStdDev  float64
+	MaxDrawdown          float64
 
-	SQN float64
+	SQN    float64
+	Sharpe float64
 }
 
 func (t AlgoScore) String() string {
-	return fmt.Sprintf("%12s|%20s|%5d|%4d|%4d:%4d|%3d:%3d| %9.2f |%9.2f|%9.2f| %7.3f", t.AlgoName, t.Symbol, t.OrdersCount, t.TradesCount, t.TradesWon, t.TradesLost, t.WinStreak, t.LossStreak, t.NetPnl, t.NetPnlPercentAverage, t.NetPnlPercentStdDev, t.SQN)
+	return fmt.Sprintf("%12s|%20s|%5d|%4d:%4d:%4d|%4d|%4d:%4d|%3d:%3d| %9.2f |%8.2f| %9.2f |%9.2f|%9.2f| %9.2f | %7.3f| %7.3f", t.AlgoName, t.Symbol, t.OrdersCount, t.OrdersCancelled, t.OrdersModified, t.OrdersRejected, t.TradesCount, t.TradesWon, t.TradesLost, t.WinStreak, t.LossStreak, t.GrossPnl, t.Charges, t.NetPnl, t.NetPnlPercentAverage, t.NetPnlPercentStdDev, t.MaxDrawdown, t.SQN, t.Sharpe)
 }
 
 type tradeEntry struct {
+	closedAt      time.Time
 	orders        int
 	buyValue      float64
 	sellValue     float64
+	charges       float64
+	grossPnl      float64
 	pnl           float64
 	pnlPercentage float64
 }
@@ -54,6 +65,20 @@ func (a *tradeData) add(t orderEntry) {
 	a.orders = append(a.orders, t)
 }
 
+// countOrderEvents counts the cancelled (or expired), modified and rejected orders
+func (a *tradeData) countOrderEvents() {
+	for _, o := range a.orders {
+		switch o.event {
+		case OrderCancelled, OrderExpired:
+			a.score.OrdersCancelled++
+		case OrderModified:
+			a.score.OrdersModified++
+		case OrderRejected:
+			a.score.OrdersRejected++
+		}
+	}
+}
+
 // reset score
 func (a *tradeData) resetScore() {
 	a.trades = make([]tradeEntry, 0)
@@ -65,47 +90,93 @@ func (a *tradeData) resetScore() {
 
 func (a *tradeData) consolidateTrades() {
 	//sort orders by time
-	sort.Slice(a.orders, func(i, j int) bool {
+	sort.SliceStable(a.orders, func(i, j int) bool {
 		return a.orders[i].at.Before(a.orders[j].at)
 	})
 
-	// consolidate orders into trades
+	// instances hold their own positions, a hedge leg of one instance does
+	// not close the trade of another
+	instances := make([]string, 0)
+	byInstance := make(map[string][]orderEntry)
+	for _, o := range a.orders {
+		if _, ok := byInstance[o.instanceID]; !ok {
+			instances = append(instances, o.instanceID)
+		}
+		byInstance[o.instanceID] = append(byInstance[o.instanceID], o)
+	}
+	for _, id := range instances {
+		a.consolidateFills(byInstance[id])
+	}
+	if len(instances) > 1 {
+		sort.SliceStable(a.trades, func(i, j int) bool {
+			return a.trades[i].closedAt.Before(a.trades[j].closedAt)
+		})
+	}
+}
+
+// consolidateFills consolidates the orders of one instance into trades
+func (a *tradeData) consolidateFills(orders []orderEntry) {
 	pos := 0
 	openTrade := tradeEntry{}
-	for _, o := range a.orders {
-		if pos == 0 {
-			openTrade.orders = 0
-			openTrade.buyValue = 0
-			openTrade.sellValue = 0
+	for _, o := range orders {
+		if !o.isFill() {
+			continue
 		}
-		pos += o.qty
-		if o.qty > 0 {
-			openTrade.buyValue = float64(o.qty) * o.price
-		} else {
-			openTrade.sellValue = -float64(o.qty) * o.price
+		qty := o.qty
+		charges := o.charges
+		if pos != 0 && pos+qty != 0 && (pos > 0) != (pos+qty > 0) {
+			// the fill flips the position, closing the trade and opening the next
+			closing := -pos
+			closingCharges := charges * float64(closing) / float64(qty)
+			a.addFill(&openTrade, &pos, closing, o.price, closingCharges, o.at)
+			qty -= closing
+			charges -= closingCharges
 		}
-		openTrade.orders++
-
-		if pos == 0 {
-			openTrade.pnl = openTrade.sellValue - openTrade.buyValue
-			if openTrade.buyValue > 0 {
-				openTrade.pnlPercentage = (openTrade.pnl)
-			} else if openTrade.pnl == 0 {
-				openTrade.pnlPercentage = 0
-			} else if openTrade.pnl < 0 {
-				openTrade.pnlPercentage = -100
-			} else {
-				openTrade.pnlPercentage = 100
-			}
-			a.trades = append(a.trades, openTrade)
+		a.addFill(&openTrade, &pos, qty, o.price, charges, o.at)
+	}
+}
+
+// addFill adds the fill to the open trade, the trade is closed when the
+// position gets flat
+func (a *tradeData) addFill(openTrade *tradeEntry, pos *int, qty int, price float64, charges float64, at time.Time) {
+	if *pos == 0 {
+		*openTrade = tradeEntry{}
+	}
+	*pos += qty
+	if qty > 0 {
+		openTrade.buyValue += float64(qty) * price
+	} else {
+		openTrade.sellValue += -float64(qty) * price
+	}
+	openTrade.charges += charges
+	openTrade.orders++
+
+	if *pos == 0 {
+		openTrade.closedAt = at
+		openTrade.grossPnl = openTrade.sellValue - openTrade.buyValue
+		openTrade.pnl = openTrade.grossPnl - openTrade.charges
+		if openTrade.buyValue > 0 {
+			openTrade.pnlPercentage = (openTrade.pnl)
+		} else if openTrade.pnl == 0 {
+			openTrade.pnlPercentage = 0
+		} else if openTrade.pnl < 0 {
+			openTrade.pnlPercentage = -100
+		} else {
+			openTrade.pnlPercentage = 100
 		}
+		a.trades = append(a.trades, *openTrade)
 	}
 }
 
 func (a *tradeData) processScore() {
 	a.resetScore()
 	a.consolidateTrades()
+	a.countOrderEvents()
+	a.scoreTrades()
+}
 
+// scoreTrades calculates the score from the consolidated trades
+func (a *tradeData) scoreTrades() {
 	a.score.TradesCount = len(a.trades)
 	pnl := make([]float64, 0)
 	winStreak := 0
@@ -123,6 +194,8 @@ func (a *tradeData) processScore() {
 				lossStreak++
 				a.score.TradesLost++
 			}
+			a.score.GrossPnl += t.grossPnl
+			a.score.Charges += t.charges
 			a.score.NetPnl += t.pnl
 			pnl = append(pnl, t.pnlPercentage)
 			if a.score.WinStreak < winStreak {
@@ -134,9 +207,9 @@ func (a *tradeData) processScore() {
 		}
 		a.score.NetPnlPercentAverage = stat.Mean(pnl, nil)
 		a.score.NetPnlPercentStdDev = stat.StdDev(pnl, nil)
-		if a.score.NetPnlPercentStdDev != 0 {
-			a.score.SQN = math.Sqrt(float64(a.score.TradesCount)) * a.score.NetPnlPercentAverage / a.score.NetPnlPercentStdDev
-		}
+		a.score.SQN = sqn(pnl)
+		a.score.MaxDrawdown = maxDrawdown(a.trades)
+		a.score.Sharpe = sharpeRatio(a.trades)
 
 	}
 }
@@ -167,3 +240,115 @@ func calculateAlgoScores(orders []orderEntry) []AlgoScore {
 
 	return scores
 }
+
+// sqn returns the system quality number of the trades pnl
+func sqn(pnl []float64) float64 {
+	if len(pnl) == 0 {
+		return 0
+	}
+	mean, std := stat.MeanStdDev(pnl, nil)
+	if std == 0 || math.IsNaN(std) {
+		return 0
+	}
+	return math.Sqrt(float64(len(pnl))) * mean / std
+}
+
+// maxDrawdown returns the largest fall of the cumulative net pnl from its
+// peak, over the trades in order
+func maxDrawdown(trades []tradeEntry) float64 {
+	equity := 0.0
+	peak := 0.0
+	drawdown := 0.0
+	for _, t := range trades {
+		equity += t.pnl
+		peak = math.Max(peak, equity)
+		drawdown = math.Max(drawdown, peak-equity)
+	}
+	return drawdown
+}
+
+// tradingDaysPerYear annualizes the daily statistics
+const tradingDaysPerYear = 252
+
+// dailyPnl returns the net pnl of the trades by the date closed
+func dailyPnl(trades []tradeEntry) map[string]float64 {
+	daily := make(map[string]float64)
+	for _, t := range trades {
+		daily[t.closedAt.Format("20060102")] += t.pnl
+	}
+	return daily
+}
+
+// sharpeRatio returns the annualized sharpe ratio of the daily net pnl, over
+// the days with trades closed
+func sharpeRatio(trades []tradeEntry) float64 {
+	daily := make([]float64, 0)
+	for _, pnl := range dailyPnl(trades) {
+		daily = append(daily, pnl)
+	}
+	// sorted, for the sums to not depend on the map order
+	sort.Float64s(daily)
+	if len(daily) < 2 {
+		return 0
+	}
+	mean, std := stat.MeanStdDev(daily, nil)
+	if std == 0 {
+		return 0
+	}
+	return math.Sqrt(tradingDaysPerYear) * mean / std
+}
+
+// calculateAlgoTotals scores the algos across the symbols traded, trades are
+// consolidated per instance and symbol and then merged in the order of closing
+func calculateAlgoTotals(orders []orderEntry) []AlgoScore {
+	names, byAlgo := ordersByAlgo(orders)
+	scores := make([]AlgoScore, 0, len(names))
+	for _, name := range names {
+		scores = append(scores, totalTrades(name, byAlgo[name]).score)
+	}
+	return scores
+}
+
+// ordersByAlgo groups the orders by algo, returns the algo names sorted
+func ordersByAlgo(orders []orderEntry) ([]string, map[string][]orderEntry) {
+	byAlgo := make(map[string][]orderEntry)
+	names := make([]string, 0)
+	for _, o := range orders {
+		if _, ok := byAlgo[o.algoName]; !ok {
+			names = append(names, o.algoName)
+		}
+		byAlgo[o.algoName] = append(byAlgo[o.algoName], o)
+	}
+	sort.Strings(names)
+	return names, byAlgo
+}
+
+// totalTrades consolidates the orders of the algo into trades across the
+// symbols, scored with Symbol set to AllSymbols
+func totalTrades(algoName string, orders []orderEntry) *tradeData {
+	bySymbol := make(map[string]*tradeData)
+	symbols := make([]string, 0)
+	for _, o := range orders {
+		if _, ok := bySymbol[o.symbol]; !ok {
+			bySymbol[o.symbol] = &tradeData{algoName: o.algoName, symbol: o.symbol}
+			symbols = append(symbols, o.symbol)
+		}
+		bySymbol[o.symbol].add(o)
+	}
+	sort.Strings(symbols)
+
+	total := &tradeData{algoName: algoName, symbol: AllSymbols, orders: orders}
+	total.resetScore()
+	for _, symbol := range symbols {
+		st := bySymbol[symbol]
+		st.resetScore()
+		st.consolidateTrades()
+		total.trades = append(total.trades, st.trades...)
+	}
+	sort.SliceStable(total.trades, func(i, j int) bool {
+		return total.trades[i].closedAt.Before(total.trades[j].closedAt)
+	})
+	total.countOrderEvents()
+	total.scoreTrades()
+	return total
+}
@@ -0,0 +1,46 @@
+package malgova
+
+import (
+	"testing"
+	"time"
+)
+
+// fill returns the fill entry of the instance at the second of the test day
+func fill(instanceID string, symbol string, sec int, qty int, price float64) orderEntry {
+	at := testStart.Add(time.Duration(sec) * time.Second)
+	return orderEntry{
+		algoName:   "pairs",
+		instanceID: instanceID,
+		symbol:     symbol,
+		event:      OrderFilled,
+		placedAt:   at,
+		at:         at,
+		qty:        qty,
+		price:      price,
+	}
+}
+
+func TestScoresPerInstance(t *testing.T) {
+	const future = "NIFTY20JULFUT"
+	orders := []orderEntry{
+		// the hedges of two instances in the same future
+		fill("pairs::SBIN", future, 0, -75, 11000),
+		fill("pairs::INFY", future, 1, 75, 11010),
+		fill("pairs::SBIN", future, 2, 75, 10990),
+		fill("pairs::INFY", future, 3, -75, 11030),
+	}
+	scores := calculateAlgoScores(orders)
+	if len(scores) != 1 {
+		t.Fatalf("%d scores, want 1", len(scores))
+	}
+	s := scores[0]
+	if s.Symbol != future || s.TradesCount != 2 || s.TradesWon != 2 {
+		t.Errorf("%s trades %d won %d, want 2 trades won", s.Symbol, s.TradesCount, s.TradesWon)
+	}
+	wantFloat(t, "gross pnl", s.GrossPnl, 75*10+75*20)
+
+	total := totalTrades("pairs", orders)
+	if len(total.trades) != 2 || !total.trades[0].closedAt.Before(total.trades[1].closedAt) {
+		t.Errorf("total trades %+v, want 2 in the order of closing", total.trades)
+	}
+}
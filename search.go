@@ -0,0 +1,306 @@
+package malgova
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/gonum/stat"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+// SearchStrategy picks the parameter sets to backtest next, from the results
+// of the sets evaluated so far, ranked best first. The sets suggested are
+// backtested together in one pass over the days.
+type SearchStrategy interface {
+	Suggest(space []ParamRange, ranked []OptimizeResult, n int, r *rand.Rand) []Params
+}
+
+// RandomSearch picks the values of every parameter at random
+type RandomSearch struct{}
+
+// Suggest method
+func (RandomSearch) Suggest(space []ParamRange, ranked []OptimizeResult, n int, r *rand.Rand) []Params {
+	sets := make([]Params, n)
+	for i := range sets {
+		sets[i] = randomParams(space, r)
+	}
+	return sets
+}
+
+// GeneticSearch evolves the parameter sets, every batch is a generation
+// bred from the best sets evaluated so far by tournament selection, uniform
+// crossover and mutation. The first generation is random.
+type GeneticSearch struct {
+	MutationRate   float64 // chance of a parameter mutating, 0.1 by default
+	TournamentSize int     // sets competing for each parent, 3 by default
+}
+
+// Suggest method
+func (g GeneticSearch) Suggest(space []ParamRange, ranked []OptimizeResult, n int, r *rand.Rand) []Params {
+	if len(ranked) < 2 {
+		return RandomSearch{}.Suggest(space, ranked, n, r)
+	}
+	mutation := g.MutationRate
+	if mutation <= 0 {
+		mutation = 0.1
+	}
+	tournament := g.TournamentSize
+	if tournament <= 0 {
+		tournament = 3
+	}
+	// ranked best first, the lowest index of the contestants wins
+	selectParent := func() Params {
+		best := r.Intn(len(ranked))
+		for i := 1; i < tournament; i++ {
+			if c := r.Intn(len(ranked)); c < best {
+				best = c
+			}
+		}
+		return ranked[best].Params
+	}
+
+	seen := make(map[string]bool)
+	for _, res := range ranked {
+		seen[res.Params.String()] = true
+	}
+	sets := make([]Params, n)
+	for i := range sets {
+		mother, father := selectParent(), selectParent()
+		child := make(Params, len(space))
+		for _, p := range space {
+			child[p.Name] = mother[p.Name]
+			if r.Float64() < 0.5 {
+				child[p.Name] = father[p.Name]
+			}
+			if r.Float64() < mutation {
+				child[p.Name] = mutate(p, child[p.Name], r)
+			}
+		}
+		// children evaluated already are mutated further, a few times at most
+		for tries := 0; seen[child.String()] && tries < 10 && len(space) > 0; tries++ {
+			p := space[r.Intn(len(space))]
+			child[p.Name] = mutate(p, child[p.Name], r)
+		}
+		seen[child.String()] = true
+		sets[i] = child
+	}
+	return sets
+}
+
+// mutate moves the value to a neighbouring value of the range, or a random
+// value one time in four
+func mutate(p ParamRange, v float64, r *rand.Rand) float64 {
+	if len(p.Values) == 0 {
+		return v
+	}
+	i := valueIndex(p, v)
+	if r.Intn(4) == 0 {
+		i = r.Intn(len(p.Values))
+	} else if r.Intn(2) == 0 {
+		i--
+	} else {
+		i++
+	}
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(p.Values) {
+		i = len(p.Values) - 1
+	}
+	return p.Values[i]
+}
+
+// BayesianSearch fits a gaussian process to the objective of the sets
+// evaluated, and suggests the sets with the highest expected improvement
+// among random candidates. Parameters are scaled to [0, 1] by their index in
+// the range.
+type BayesianSearch struct {
+	InitialPoints int     // random sets before the model is fitted, 10 by default
+	Candidates    int     // random candidates scored per batch, 1000 by default
+	LengthScale   float64 // of the squared exponential kernel, 0.2 by default
+	Noise         float64 // variance of the objective noise, 0.01 by default
+}
+
+// Suggest method
+func (b BayesianSearch) Suggest(space []ParamRange, ranked []OptimizeResult, n int, r *rand.Rand) []Params {
+	initial := b.InitialPoints
+	if initial <= 0 {
+		initial = 10
+	}
+	x := make([][]float64, 0, len(ranked))
+	y := make([]float64, 0, len(ranked))
+	for _, res := range ranked {
+		if math.IsNaN(res.Objective) || math.IsInf(res.Objective, 0) {
+			continue
+		}
+		x = append(x, scaleParams(space, res.Params))
+		y = append(y, res.Objective)
+	}
+	if len(x) < initial {
+		return RandomSearch{}.Suggest(space, ranked, n, r)
+	}
+	gp, ok := newGaussianProcess(x, y, b.lengthScale(), b.noise())
+	if !ok {
+		return RandomSearch{}.Suggest(space, ranked, n, r)
+	}
+
+	candidates := b.Candidates
+	if candidates <= 0 {
+		candidates = 1000
+	}
+	type scored struct {
+		params Params
+		ei     float64
+	}
+	pool := make([]scored, 0, candidates)
+	seen := make(map[string]bool)
+	for _, res := range ranked {
+		seen[res.Params.String()] = true
+	}
+	for i := 0; i < candidates; i++ {
+		p := randomParams(space, r)
+		key := p.String()
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		pool = append(pool, scored{params: p, ei: gp.expectedImprovement(scaleParams(space, p))})
+	}
+	sort.SliceStable(pool, func(i, j int) bool {
+		return pool[i].ei > pool[j].ei
+	})
+	sets := make([]Params, 0, n)
+	for i := 0; i < n && i < len(pool); i++ {
+		sets = append(sets, pool[i].params)
+	}
+	return sets
+}
+
+func (b BayesianSearch) lengthScale() float64 {
+	if b.LengthScale <= 0 {
+		return 0.2
+	}
+	return b.LengthScale
+}
+
+func (b BayesianSearch) noise() float64 {
+	if b.Noise <= 0 {
+		return 0.01
+	}
+	return b.Noise
+}
+
+// gaussianProcess with zero mean prior over the standardized objective
+type gaussianProcess struct {
+	x           [][]float64
+	alpha       *mat.VecDense
+	chol        mat.Cholesky
+	lengthScale float64
+	mean        float64
+	std         float64
+	best        float64
+}
+
+func newGaussianProcess(x [][]float64, y []float64, lengthScale float64, noise float64) (*gaussianProcess, bool) {
+	gp := &gaussianProcess{x: x, lengthScale: lengthScale}
+	gp.mean, gp.std = stat.MeanStdDev(y, nil)
+	if gp.std == 0 || math.IsNaN(gp.std) {
+		gp.std = 1
+	}
+	z := make([]float64, len(y))
+	gp.best = math.Inf(-1)
+	for i, v := range y {
+		z[i] = (v - gp.mean) / gp.std
+		gp.best = math.Max(gp.best, z[i])
+	}
+	k := mat.NewSymDense(len(x), nil)
+	for i := range x {
+		for j := i; j < len(x); j++ {
+			v := gp.kernel(x[i], x[j])
+			if i == j {
+				v += noise
+			}
+			k.SetSym(i, j, v)
+		}
+	}
+	if !gp.chol.Factorize(k) {
+		return nil, false
+	}
+	gp.alpha = mat.NewVecDense(len(z), nil)
+	if err := gp.chol.SolveVecTo(gp.alpha, mat.NewVecDense(len(z), z)); err != nil {
+		return nil, false
+	}
+	return gp, true
+}
+
+// kernel is the squared exponential covariance
+func (gp *gaussianProcess) kernel(a []float64, b []float64) float64 {
+	d := 0.0
+	for i := range a {
+		d += (a[i] - b[i]) * (a[i] - b[i])
+	}
+	return math.Exp(-d / (2 * gp.lengthScale * gp.lengthScale))
+}
+
+// predict returns the posterior mean and standard deviation at the point
+func (gp *gaussianProcess) predict(p []float64) (float64, float64) {
+	ks := mat.NewVecDense(len(gp.x), nil)
+	for i, x := range gp.x {
+		ks.SetVec(i, gp.kernel(x, p))
+	}
+	mu := mat.Dot(ks, gp.alpha)
+	v := mat.NewVecDense(len(gp.x), nil)
+	if err := gp.chol.SolveVecTo(v, ks); err != nil {
+		return mu, 0
+	}
+	variance := 1 - mat.Dot(ks, v)
+	if variance < 0 {
+		variance = 0
+	}
+	return mu, math.Sqrt(variance)
+}
+
+// expectedImprovement over the best objective evaluated
+func (gp *gaussianProcess) expectedImprovement(p []float64) float64 {
+	mu, sigma := gp.predict(p)
+	if sigma == 0 {
+		return math.Max(mu-gp.best, 0)
+	}
+	z := (mu - gp.best) / sigma
+	return (mu-gp.best)*distuv.UnitNormal.CDF(z) + sigma*distuv.UnitNormal.Prob(z)
+}
+
+// randomParams picks a random value of every parameter
+func randomParams(space []ParamRange, r *rand.Rand) Params {
+	p := make(Params, len(space))
+	for _, pr := range space {
+		if len(pr.Values) > 0 {
+			p[pr.Name] = pr.Values[r.Intn(len(pr.Values))]
+		}
+	}
+	return p
+}
+
+// scaleParams maps the parameters to [0, 1] by their index in the range
+func scaleParams(space []ParamRange, p Params) []float64 {
+	x := make([]float64, len(space))
+	for i, pr := range space {
+		if len(pr.Values) > 1 {
+			x[i] = float64(valueIndex(pr, p[pr.Name])) / float64(len(pr.Values)-1)
+		}
+	}
+	return x
+}
+
+// valueIndex returns the index of the value nearest to v in the range
+func valueIndex(p ParamRange, v float64) int {
+	best := 0
+	for i, pv := range p.Values {
+		if math.Abs(pv-v) < math.Abs(p.Values[best]-v) {
+			best = i
+		}
+	}
+	return best
+}
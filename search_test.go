@@ -0,0 +1,133 @@
+package malgova
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+var testSpace = []ParamRange{
+	Range("fast", 5, 50, 5),
+	Range("slow", 50, 200, 10),
+}
+
+// inSpace checks every parameter of the set takes a value of its range
+func inSpace(t *testing.T, p Params) {
+	t.Helper()
+	for _, r := range testSpace {
+		if v, ok := p[r.Name]; !ok || math.Abs(r.Values[valueIndex(r, v)]-v) > 1e-9 {
+			t.Errorf("%s out of the space", p)
+		}
+	}
+}
+
+// rankedBy evaluates the sets by the objective, ranked best first
+func rankedBy(sets []Params, objective func(p Params) float64) []OptimizeResult {
+	results := make([]OptimizeResult, len(sets))
+	for i, p := range sets {
+		results[i] = OptimizeResult{Params: p, Objective: objective(p)}
+	}
+	rankResults(results)
+	return results
+}
+
+func TestValueIndex(t *testing.T) {
+	r := Range("fast", 5, 50, 5)
+	tests := map[float64]int{5: 0, 6: 0, 8: 1, 50: 9, 100: 9, -1: 0}
+	for v, want := range tests {
+		if got := valueIndex(r, v); got != want {
+			t.Errorf("%g: got %d, want %d", v, got, want)
+		}
+	}
+	x := scaleParams(testSpace, Params{"fast": 50, "slow": 50})
+	if x[0] != 1 || x[1] != 0 {
+		t.Errorf("scaled %v", x)
+	}
+}
+
+func TestMutate(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	p := Range("fast", 5, 50, 5)
+	for i := 0; i < 1000; i++ {
+		v := mutate(p, p.Values[i%len(p.Values)], r)
+		if v < 5 || v > 50 || p.Values[valueIndex(p, v)] != v {
+			t.Fatalf("mutated to %g", v)
+		}
+	}
+	if v := mutate(Choice("none"), 3, r); v != 3 {
+		t.Errorf("empty range mutated to %g", v)
+	}
+}
+
+func TestSearchSuggest(t *testing.T) {
+	objective := func(p Params) float64 {
+		return -math.Abs(p["fast"]-20) - math.Abs(p["slow"]-120)
+	}
+	r := rand.New(rand.NewSource(1))
+	ranked := rankedBy(RandomSearch{}.Suggest(testSpace, nil, 20, r), objective)
+	tests := []struct {
+		name   string
+		search SearchStrategy
+	}{
+		{"random", RandomSearch{}},
+		{"genetic", GeneticSearch{}},
+		{"bayesian", BayesianSearch{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sets := tt.search.Suggest(testSpace, ranked, 10, rand.New(rand.NewSource(2)))
+			if len(sets) != 10 {
+				t.Fatalf("%d sets, want 10", len(sets))
+			}
+			for _, p := range sets {
+				inSpace(t, p)
+			}
+		})
+	}
+	t.Run("bayesian sets are not evaluated already", func(t *testing.T) {
+		evaluated := make(map[string]bool)
+		for _, res := range ranked {
+			evaluated[res.Params.String()] = true
+		}
+		seen := make(map[string]bool)
+		for _, p := range (BayesianSearch{}).Suggest(testSpace, ranked, 10, rand.New(rand.NewSource(2))) {
+			if evaluated[p.String()] || seen[p.String()] {
+				t.Errorf("%s suggested again", p)
+			}
+			seen[p.String()] = true
+		}
+	})
+	t.Run("first generation is random", func(t *testing.T) {
+		a := GeneticSearch{}.Suggest(testSpace, ranked[:1], 5, rand.New(rand.NewSource(3)))
+		b := RandomSearch{}.Suggest(testSpace, nil, 5, rand.New(rand.NewSource(3)))
+		for i := range a {
+			if a[i].String() != b[i].String() {
+				t.Errorf("%s, want the random %s", a[i], b[i])
+			}
+		}
+	})
+}
+
+func TestGaussianProcess(t *testing.T) {
+	x := [][]float64{{0}, {0.5}, {1}}
+	y := []float64{1, 3, 2}
+	gp, ok := newGaussianProcess(x, y, 0.2, 1e-6)
+	if !ok {
+		t.Fatal("kernel not factorized")
+	}
+	for i := range x {
+		mu, sigma := gp.predict(x[i])
+		if got := mu*gp.std + gp.mean; math.Abs(got-y[i]) > 1e-3 || sigma > 1e-2 {
+			t.Errorf("at %v: mean %.4f sd %.4f, want %.1f and no uncertainty", x[i], got, sigma, y[i])
+		}
+	}
+	if _, sigma := gp.predict([]float64{5}); math.Abs(sigma-1) > 1e-6 {
+		t.Errorf("sd far from the points %.4f, want the prior 1", sigma)
+	}
+	if ei := gp.expectedImprovement([]float64{0.5}); ei > 1e-3 {
+		t.Errorf("expected improvement at the best point %.4f", ei)
+	}
+	if gp.expectedImprovement([]float64{5}) <= gp.expectedImprovement([]float64{0}) {
+		t.Error("unexplored point improves less than the worst point")
+	}
+}
@@ -0,0 +1,33 @@
+package malgova
+
+// AllSymbols subscribes to the ticks of every symbol in the feed
+const AllSymbols = "*"
+
+// Subscribe to the ticks of the symbol, tradable or not, from the next tick
+// onwards. Symbols returned by Setup are subscribed already.
+func (b *Book) Subscribe(symbol string) {
+	for _, s := range b.subscriptions {
+		if s == symbol {
+			return
+		}
+	}
+	b.subscriptions = append(b.subscriptions, symbol)
+	b.subscriptionsChanged = true
+}
+
+// Unsubscribe from the ticks of the symbol, working orders in the symbol
+// are not filled without its ticks
+func (b *Book) Unsubscribe(symbol string) {
+	for i, s := range b.subscriptions {
+		if s == symbol {
+			b.subscriptions = append(b.subscriptions[:i], b.subscriptions[i+1:]...)
+			b.subscriptionsChanged = true
+			return
+		}
+	}
+}
+
+// Subscriptions returns the symbols subscribed
+func (b *Book) Subscriptions() []string {
+	return append([]string(nil), b.subscriptions...)
+}
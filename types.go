@@ -6,15 +6,46 @@ import (
 	"github.com/sivamgr/kstreamdb"
 )
 
-// Book struct
+// Book struct, Position, AvgPrice and MaxPositionHeld are of the instance
+// symbol, the pnl, turnover and charges are totals across the symbols traded
 type Book struct {
-	CashAllocated        float64
-	Cash                 float64
-	Position             int
-	IsMarketOrder        bool
-	PendingOrderQuantity int
-	PendingOrderPrice    float64
-	OrderCount           int
+	CashAllocated float64
+	Cash          float64
+	Position      int
+	OrderCount    int
+
+	AvgPrice        float64
+	RealizedPnl     float64
+	UnrealizedPnl   float64
+	Turnover        float64
+	Charges         float64
+	MaxPositionHeld int
+
+	LotSize      int
+	CircuitLimit float64 // percent band around previous close of equities, zero disables
+	Margin       *MarginModel
+	// SymbolLotSize and SymbolCircuitLimit override LotSize and CircuitLimit
+	// for the symbols traded, of any segment
+	SymbolLotSize      map[string]int
+	SymbolCircuitLimit map[string]float64
+
+	orders      map[int]*Order
+	working     []*Order
+	lastOrderID int
+	lastGroupID int
+	brackets    map[int]*bracket
+	updates     []OrderUpdate
+	clock       time.Time
+	quotes      map[string]kstreamdb.TickData
+	holdings    map[string]*Holding
+	symbol      string
+	squaredOff  bool
+	exitOnly    bool
+	portfolio   *Portfolio
+	instanceID  string
+
+	subscriptions        []string
+	subscriptionsChanged bool
 }
 
 // OrderManager Interface
@@ -40,6 +71,25 @@ type AlgoStrategy interface {
 	OnClose(b *Book)
 }
 
+// PortfolioStrategy Interface, strategies instantiated once for the engine
+// instead of once per symbol. Setup returns the universe of symbols to
+// receive the ticks of, AllSymbols for every symbol in the feed. Orders are
+// placed in any symbol through the symbol order methods of the book.
+type PortfolioStrategy interface {
+	Setup(b *Book) []string
+	OnDayStart(b *Book)
+	OnDayEnd(b *Book)
+	OnTick(t kstreamdb.TickData, b *Book)
+	OnPeriodic(t time.Time, b *Book) // Invokes every sec
+	OnClose(b *Book)
+}
+
+// OrderObserver Interface, optionally implemented by algo strategies to
+// receive order lifecycle updates
+type OrderObserver interface {
+	OnOrderUpdate(u OrderUpdate, b *Book)
+}
+
 /*
 func getType(myvar interface{}) string {
 	t := reflect.TypeOf(myvar)
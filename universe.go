@@ -0,0 +1,79 @@
+package malgova
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sivamgr/kstreamdb"
+)
+
+// Universe Interface, the symbols an algo is instantiated for on the date.
+// prevDay holds the last tick of each symbol of the previous trading day of
+// the run, nil on the first day. The last tick carries the day open, high,
+// low, close and the volume traded.
+type Universe interface {
+	Symbols(date time.Time, prevDay []kstreamdb.TickData) []string
+}
+
+// StaticUniverse is a fixed list of symbols
+type StaticUniverse []string
+
+// Symbols method
+func (u StaticUniverse) Symbols(date time.Time, prevDay []kstreamdb.TickData) []string {
+	return u
+}
+
+// UniverseFunc selects the symbols of the day from the previous day
+type UniverseFunc func(date time.Time, prevDay []kstreamdb.TickData) []string
+
+// Symbols method
+func (f UniverseFunc) Symbols(date time.Time, prevDay []kstreamdb.TickData) []string {
+	return f(date, prevDay)
+}
+
+// FileUniverse reads the symbols from the file, one symbol per line. Blank
+// lines and lines starting with # are skipped.
+func FileUniverse(filePath string) (StaticUniverse, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	u := make(StaticUniverse, 0)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		symbol := strings.TrimSpace(scanner.Text())
+		if symbol == "" || strings.HasPrefix(symbol, "#") {
+			continue
+		}
+		u = append(u, symbol)
+	}
+	return u, scanner.Err()
+}
+
+// needsPrevDay checks if any of the universes selects on the previous day
+func needsPrevDay(universes map[string]Universe) bool {
+	for _, u := range universes {
+		if _, ok := u.(StaticUniverse); !ok {
+			return true
+		}
+	}
+	return false
+}
+
+// lastTicks returns the last tick of each symbol, in the order of the feed
+func lastTicks(ticks []kstreamdb.TickData) []kstreamdb.TickData {
+	last := make(map[string]int)
+	for i, t := range ticks {
+		last[t.TradingSymbol] = i
+	}
+	summary := make([]kstreamdb.TickData, 0, len(last))
+	for i, t := range ticks {
+		if last[t.TradingSymbol] == i {
+			summary = append(summary, t)
+		}
+	}
+	return summary
+}
@@ -0,0 +1,127 @@
+package malgova
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sivamgr/kstreamdb"
+)
+
+func TestFileUniverse(t *testing.T) {
+	dir, err := ioutil.TempDir("", "universe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "nifty.txt")
+	if err := ioutil.WriteFile(path, []byte("# banks\nSBIN\n\n  HDFCBANK \nINFY\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	u, err := FileUniverse(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (StaticUniverse{"SBIN", "HDFCBANK", "INFY"}); !reflect.DeepEqual(u, want) {
+		t.Errorf("got %v, want %v", u, want)
+	}
+	if _, err := FileUniverse(filepath.Join(dir, "missing.txt")); err == nil {
+		t.Error("missing file read")
+	}
+}
+
+func TestLastTicks(t *testing.T) {
+	ticks := []kstreamdb.TickData{
+		testTick(0, 100, depth(), depth()),
+		of("INFY", testTick(1, 200, depth(), depth())),
+		testTick(2, 101, depth(), depth()),
+		indexTick(3, 11000),
+		of("INFY", testTick(4, 199, depth(), depth())),
+	}
+	got := lastTicks(ticks)
+	if len(got) != 3 || got[0].TradingSymbol != testSymbol || got[0].LastPrice != 101 ||
+		got[1].TradingSymbol != "NIFTY 50" || got[2].LastPrice != 199 {
+		t.Errorf("last ticks %+v", got)
+	}
+	if got := lastTicks(nil); len(got) != 0 {
+		t.Errorf("last ticks of no ticks %+v", got)
+	}
+}
+
+func TestNeedsPrevDay(t *testing.T) {
+	static := map[string]Universe{"a": StaticUniverse{"SBIN"}}
+	if needsPrevDay(static) || needsPrevDay(nil) {
+		t.Error("static universe needs the previous day")
+	}
+	static["b"] = UniverseFunc(func(date time.Time, prevDay []kstreamdb.TickData) []string { return nil })
+	if !needsPrevDay(static) {
+		t.Error("universe func does not need the previous day")
+	}
+}
+
+func TestUniverseSelection(t *testing.T) {
+	day := func(date time.Time, closes map[string]float32) []kstreamdb.TickData {
+		ticks := make([]kstreamdb.TickData, 0)
+		for i, symbol := range []string{"SBIN", "INFY", "TCS"} {
+			tick := of(symbol, testTick(i, 100, depth(99.5, 1000), depth(100.5, 1000)))
+			tick.Timestamp = date.Add(time.Duration(i) * time.Second)
+			ticks = append(ticks, tick)
+		}
+		for i, symbol := range []string{"SBIN", "INFY", "TCS"} {
+			tick := of(symbol, testTick(i, closes[symbol], depth(closes[symbol]-0.5, 1000), depth(closes[symbol], 1000)))
+			tick.Timestamp = date.Add(time.Duration(10+i) * time.Second)
+			ticks = append(ticks, tick)
+		}
+		return ticks
+	}
+	var prevDays [][]kstreamdb.TickData
+	// the symbols closing above 100 on the previous day, all on the first day
+	gainers := UniverseFunc(func(date time.Time, prevDay []kstreamdb.TickData) []string {
+		prevDays = append(prevDays, prevDay)
+		if prevDay == nil {
+			return []string{"SBIN", "INFY", "TCS"}
+		}
+		symbols := make([]string, 0)
+		for _, t := range prevDay {
+			if t.LastPrice > 100 {
+				symbols = append(symbols, t.TradingSymbol)
+			}
+		}
+		return symbols
+	})
+	spec := algoSpec{name: "gapper", newInstance: func() interface{} {
+		// INFY buys on the first tick of both the days
+		buy := func(b *Book) {
+			if b.symbol == "INFY" {
+				b.Buy(10)
+			}
+		}
+		return &scriptAlgo{script: map[int]func(b *Book){0: buy, 2: buy}}
+	}}
+	bt := new(btDayRunner)
+	bt.setup([]algoSpec{spec}, nil, btConfig{universe: map[string]Universe{"gapper": gainers}})
+	first := testStart.Truncate(24 * time.Hour)
+	bt.run(first, day(first, map[string]float32{"SBIN": 105, "INFY": 95, "TCS": 95}))
+	second := first.Add(24 * time.Hour)
+	bt.run(second, day(second, map[string]float32{"SBIN": 105, "INFY": 95, "TCS": 95}))
+
+	if len(prevDays) != 2 || prevDays[0] != nil || len(prevDays[1]) != 3 || prevDays[1][0].LastPrice != 105 {
+		t.Fatalf("previous days %+v", prevDays)
+	}
+	sbin, infy, tcs := bt.algoRunner["gapper::SBIN"], bt.algoRunner["gapper::INFY"], bt.algoRunner["gapper::TCS"]
+	if !sbin.enable || sbin.book.exitOnly {
+		t.Error("SBIN in the universe is not enabled")
+	}
+	if !infy.enable || !infy.book.exitOnly {
+		t.Errorf("INFY with a position: enabled %v exit only %v, want exit only", infy.enable, infy.book.exitOnly)
+	}
+	if tcs.enable {
+		t.Error("flat TCS out of the universe is enabled")
+	}
+	if o := order(t, &infy.book, 2); o.RejectReason != ErrNotInUniverse.Error() {
+		t.Errorf("opening order of INFY: %s %q", o.Status, o.RejectReason)
+	}
+}
@@ -0,0 +1,129 @@
+package malgova
+
+import (
+	"math"
+)
+
+// validate the order before it gets to the working orders
+func (b *Book) validate(o *Order) error {
+	if o.Symbol == "" {
+		return ErrNoSymbol
+	}
+	if o.Quantity <= 0 {
+		return ErrInvalidQuantity
+	}
+	if lot := b.lotSize(o.Symbol); lot > 1 && o.Quantity%lot != 0 {
+		return ErrLotSize
+	}
+	if (o.Type != OrderTypeMarket && o.Type != OrderTypeStopMarket && o.Price <= 0) ||
+		(o.IsStop() && o.TriggerPrice <= 0) {
+		return ErrInvalidPrice
+	}
+	if b.orderValuePrice(o) <= 0 {
+		// market orders of a symbol yet to tick can not be checked for funds
+		return ErrNoQuote
+	}
+	if o.TimeInForce == TIFGTT && !o.ExpireAt.After(b.clock) {
+		return ErrInvalidExpiry
+	}
+	if o.IsStop() && o.ParentID == 0 && b.isTriggerCrossed(o) {
+		return ErrTriggerCrossed
+	}
+	if !b.isWithinCircuit(o.Symbol, o.Price) || !b.isWithinCircuit(o.Symbol, o.TriggerPrice) {
+		return ErrCircuitLimit
+	}
+	if b.squaredOff && b.openingQuantity(o) > 0 {
+		return ErrSquareOffTime
+	}
+	if b.exitOnly && b.openingQuantity(o) > 0 {
+		return ErrNotInUniverse
+	}
+	required := b.marginRequired(o)
+	if required > b.freeCash(o.ID) {
+		return ErrInsufficientFunds
+	}
+	if b.portfolio != nil {
+		// a modified order is checked for the funds beyond its reservation
+		if w, ok := b.orders[o.ID]; ok && w != o && w.IsOpen() {
+			required -= b.marginRequired(w)
+		}
+		return b.portfolio.check(b.instanceID, o.Symbol, required)
+	}
+	return nil
+}
+
+// isTriggerCrossed checks the stop trigger against the last price, a buy stop
+// at or below it and a sell stop at or above it would trigger right away.
+// Bracket stop-losses are exempt, they are placed when the entry fills.
+func (b *Book) isTriggerCrossed(o *Order) bool {
+	ltp := float64(b.quoteOf(o.Symbol).LastPrice)
+	if ltp <= 0 {
+		return false
+	}
+	if o.Side == SideBuy {
+		return o.TriggerPrice <= ltp
+	}
+	return o.TriggerPrice >= ltp
+}
+
+// lotSize returns the lot size of the symbol, LotSize when not set for it
+func (b *Book) lotSize(symbol string) int {
+	if lot, ok := b.SymbolLotSize[symbol]; ok {
+		return lot
+	}
+	return b.LotSize
+}
+
+// circuitLimit returns the circuit band of the symbol. Futures and options
+// have no fixed band, CircuitLimit applies to the equities only.
+func (b *Book) circuitLimit(symbol string) float64 {
+	if limit, ok := b.SymbolCircuitLimit[symbol]; ok {
+		return limit
+	}
+	if SegmentOf(symbol) != SegmentEquity {
+		return 0
+	}
+	return b.CircuitLimit
+}
+
+// isWithinCircuit checks the price against the circuit band around previous close
+func (b *Book) isWithinCircuit(symbol string, price float64) bool {
+	ref := float64(b.quoteOf(symbol).LastDayClose)
+	limit := b.circuitLimit(symbol)
+	if price <= 0 || ref <= 0 || limit <= 0 {
+		return true
+	}
+	band := ref * limit / 100
+	return price >= ref-band && price <= ref+band
+}
+
+// orderValuePrice returns the price the order is valued at for the funds check
+func (b *Book) orderValuePrice(o *Order) float64 {
+	switch o.Type {
+	case OrderTypeLimit, OrderTypeStopLimit:
+		return o.Price
+	case OrderTypeStopMarket:
+		return o.TriggerPrice
+	}
+	return marketPrice(o.Side, b.quoteOf(o.Symbol))
+}
+
+// openingQuantity returns the part of the order quantity that adds to the
+// position, the rest closes the existing position
+func (b *Book) openingQuantity(o *Order) int {
+	qty := o.PendingQuantity()
+	pos := b.PositionOf(o.Symbol)
+	if (o.Side == SideBuy && pos < 0) || (o.Side == SideSell && pos > 0) {
+		closing := int(math.Min(float64(qty), math.Abs(float64(pos))))
+		qty -= closing
+	}
+	return qty
+}
+
+// reject the order with the reason
+func (b *Book) reject(o *Order, err error) {
+	o.Status = OrderStatusRejected
+	o.RejectReason = err.Error()
+	o.UpdatedAt = b.clock
+	b.notify(OrderRejected, o, 0, 0)
+}
@@ -0,0 +1,192 @@
+package malgova
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sivamgr/kstreamdb"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		symbol string
+		setup  func(b *Book)
+		watch  []string
+		ticks  []kstreamdb.TickData
+		place  func(b *Book)
+		want   error
+	}{
+		{
+			name:  "zero quantity",
+			place: func(b *Book) { b.Buy(0) },
+			want:  ErrInvalidQuantity,
+		},
+		{
+			name:  "limit without price",
+			place: func(b *Book) { b.BuyLimit(10, 0) },
+			want:  ErrInvalidPrice,
+		},
+		{
+			name:  "quantity not in lots",
+			setup: func(b *Book) { b.LotSize = 50 },
+			place: func(b *Book) { b.Buy(30) },
+			want:  ErrLotSize,
+		},
+		{
+			name:  "price outside the circuit band",
+			place: func(b *Book) { b.BuyLimit(10, 130) },
+			want:  ErrCircuitLimit,
+		},
+		{
+			name:   "no circuit band for futures",
+			symbol: "NIFTY20JULFUT",
+			place:  func(b *Book) { b.BuyLimit(10, 130) },
+		},
+		{
+			name:   "quantity not in lots of the symbol",
+			symbol: "NIFTY20JULFUT",
+			setup:  func(b *Book) { b.SymbolLotSize = map[string]int{"NIFTY20JULFUT": 75} },
+			place:  func(b *Book) { b.Buy(50) },
+			want:   ErrLotSize,
+		},
+		{
+			name:   "quantity in lots of the symbol",
+			symbol: "NIFTY20JULFUT",
+			setup:  func(b *Book) { b.LotSize = 50; b.SymbolLotSize = map[string]int{"NIFTY20JULFUT": 75} },
+			place:  func(b *Book) { b.Buy(75) },
+		},
+		{
+			name:   "circuit band of the symbol",
+			symbol: "NIFTY20JULFUT",
+			setup:  func(b *Book) { b.SymbolCircuitLimit = map[string]float64{"NIFTY20JULFUT": 10} },
+			place:  func(b *Book) { b.BuyLimit(10, 115) },
+			want:   ErrCircuitLimit,
+		},
+		{
+			name:  "insufficient funds",
+			setup: func(b *Book) { b.AllocateCash(1000) },
+			place: func(b *Book) { b.Buy(100) },
+			want:  ErrInsufficientFunds,
+		},
+		{
+			name:  "insufficient funds with the working orders",
+			setup: func(b *Book) { b.AllocateCash(15000) },
+			place: func(b *Book) { b.BuyLimit(100, 99); b.BuyLimit(100, 99) },
+			want:  ErrInsufficientFunds,
+		},
+		{
+			name:  "market order before the first tick of the symbol",
+			watch: []string{"NIFTY 50"},
+			ticks: []kstreamdb.TickData{indexTick(0, 11000)},
+			place: func(b *Book) { b.Buy(1000000) },
+			want:  ErrNoQuote,
+		},
+		{
+			name:  "order of a watched symbol yet to tick",
+			watch: []string{"INFY"},
+			place: func(b *Book) { b.BuySymbol("INFY", 1000000) },
+			want:  ErrNoQuote,
+		},
+		{
+			name:  "market order naming a symbol yet to tick",
+			watch: []string{"INFY"},
+			place: func(b *Book) {
+				b.PlaceOrder(Order{Symbol: "INFY", Side: SideSell, Type: OrderTypeMarket, Quantity: 10})
+			},
+			want: ErrNoQuote,
+		},
+		{
+			name:  "order of a watched symbol after its tick",
+			watch: []string{"INFY"},
+			ticks: []kstreamdb.TickData{of("INFY", quietTick())},
+			place: func(b *Book) { b.BuySymbol("INFY", 10) },
+		},
+		{
+			name:  "stop order before the first tick of the symbol",
+			watch: []string{"NIFTY 50"},
+			ticks: []kstreamdb.TickData{indexTick(0, 11000)},
+			place: func(b *Book) { b.BuyStop(10, 101) },
+		},
+		{
+			name:  "buy stop trigger below the last price",
+			place: func(b *Book) { b.BuyStop(10, 99) },
+			want:  ErrTriggerCrossed,
+		},
+		{
+			name:  "sell stop trigger above the last price",
+			place: func(b *Book) { b.SellStop(10, 101) },
+			want:  ErrTriggerCrossed,
+		},
+		{
+			name:  "buy stop trigger above the last price",
+			place: func(b *Book) { b.BuyStop(10, 101) },
+		},
+		{
+			name: "GTT without expiry",
+			place: func(b *Book) {
+				b.PlaceOrder(Order{Side: SideBuy, Type: OrderTypeLimit, Quantity: 10, Price: 99, TimeInForce: TIFGTT})
+			},
+			want: ErrInvalidExpiry,
+		},
+		{
+			name: "GTT expiring before the tick",
+			place: func(b *Book) {
+				b.PlaceOrder(Order{Side: SideBuy, Type: OrderTypeLimit, Quantity: 10, Price: 99, TimeInForce: TIFGTT, ExpireAt: testStart.Add(-time.Minute)})
+			},
+			want: ErrInvalidExpiry,
+		},
+		{
+			name: "GTT expiring later",
+			place: func(b *Book) {
+				b.PlaceOrder(Order{Side: SideBuy, Type: OrderTypeLimit, Quantity: 10, Price: 99, TimeInForce: TIFGTT, ExpireAt: testStart.Add(time.Minute)})
+			},
+		},
+		{
+			name:  "stop without trigger",
+			place: func(b *Book) { b.SellStop(10, 0) },
+			want:  ErrInvalidPrice,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := tickCase{
+				symbol: tt.symbol,
+				setup:  tt.setup,
+				watch:  tt.watch,
+				script: map[int]func(b *Book){0: tt.place},
+				ticks:  tt.ticks,
+			}
+			if c.ticks == nil {
+				c.ticks = []kstreamdb.TickData{quietTick()}
+			}
+			a := c.run()
+			// the last order placed is checked
+			o := order(t, &a.book, a.book.lastOrderID)
+			if tt.want == nil {
+				if o.Status == OrderStatusRejected {
+					t.Errorf("rejected: %s", o.RejectReason)
+				}
+			} else if o.Status != OrderStatusRejected || o.RejectReason != tt.want.Error() {
+				t.Errorf("got %s %q, want rejected %q", o.Status, o.RejectReason, tt.want)
+			}
+		})
+	}
+}
+
+func TestFundsBeforeFirstTick(t *testing.T) {
+	runTickCases(t, []tickCase{
+		{
+			name:   "market order on the index tick does not fill",
+			watch:  []string{"NIFTY 50"},
+			script: map[int]func(b *Book){0: func(b *Book) { b.Buy(1000000) }},
+			ticks:  []kstreamdb.TickData{indexTick(0, 11000), testTick(1, 100, depth(99.5, 1000000), depth(100, 1000000))},
+			check: func(t *testing.T, b *Book) {
+				wantOrder(t, b, 1, OrderStatusRejected, 0, 0)
+				if b.Cash != 1000000 || b.Position != 0 {
+					t.Errorf("cash %.2f position %d, want untouched", b.Cash, b.Position)
+				}
+			},
+		},
+	})
+}
@@ -0,0 +1,118 @@
+package malgova
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sivamgr/kstreamdb"
+)
+
+// WalkForward struct, the window lengths in trading days. Each window
+// optimizes the parameters over the in-sample days and trades the best set
+// over the following out-of-sample days, then rolls forward by the
+// out-of-sample days.
+type WalkForward struct {
+	InSample    int
+	OutOfSample int
+	Anchored    bool // in-sample windows start from the first day
+}
+
+// WalkForwardWindow struct, the in-sample and out-of-sample result of the
+// window
+type WalkForwardWindow struct {
+	InSampleStart        time.Time
+	InSampleEnd          time.Time
+	OutOfSampleStart     time.Time
+	OutOfSampleEnd       time.Time
+	Params               Params
+	InSample             AlgoScore
+	OutOfSample          AlgoScore
+	InSampleObjective    float64
+	OutOfSampleObjective float64
+	// Efficiency is the out-of-sample net pnl per day over the in-sample net
+	// pnl per day, zero when the in-sample net pnl is not positive
+	Efficiency float64
+}
+
+func (w WalkForwardWindow) String() string {
+	return fmt.Sprintf("%s - %s | %s - %s | %s | %9.3f | %9.3f | %9.2f | %9.2f | %6.2f",
+		w.InSampleStart.Format("2006/01/02"), w.InSampleEnd.Format("2006/01/02"),
+		w.OutOfSampleStart.Format("2006/01/02"), w.OutOfSampleEnd.Format("2006/01/02"),
+		w.Params, w.InSampleObjective, w.OutOfSampleObjective, w.InSample.NetPnl, w.OutOfSample.NetPnl, w.Efficiency)
+}
+
+// WalkForwardResult struct, the windows and the score of the out-of-sample
+// trades stitched together
+type WalkForwardResult struct {
+	Windows []WalkForwardWindow
+	Score   AlgoScore
+}
+
+// windows splits the dates into the in-sample and out-of-sample
+// windows, the last out-of-sample window may be shorter
+func (wf WalkForward) windows(dates []time.Time) [][2][]time.Time {
+	windows := make([][2][]time.Time, 0)
+	if wf.InSample <= 0 || wf.OutOfSample <= 0 {
+		return windows
+	}
+	for start := 0; start+wf.InSample < len(dates); start += wf.OutOfSample {
+		isStart := start
+		if wf.Anchored {
+			isStart = 0
+		}
+		oosStart := start + wf.InSample
+		oosEnd := oosStart + wf.OutOfSample
+		if oosEnd > len(dates) {
+			oosEnd = len(dates)
+		}
+		windows = append(windows, [2][]time.Time{dates[isStart:oosStart], dates[oosStart:oosEnd]})
+	}
+	return windows
+}
+
+// WalkForward optimizes the algo over rolling in-sample windows and trades
+// the best parameter set over the out-of-sample window following each. The
+// out-of-sample orders of all the windows make up the ledger of the engine,
+// reported by Scores and TotalScores under the optimizer name.
+func (bt *BacktestEngine) WalkForward(feed *kstreamdb.DB, opt Optimizer, wf WalkForward) WalkForwardResult {
+	dates, _ := feed.GetDates()
+	result := WalkForwardResult{Windows: make([]WalkForwardWindow, 0)}
+	orders := make([]orderEntry, 0)
+
+	for _, days := range wf.windows(dates) {
+		inSample, outOfSample := days[0], days[1]
+		w := WalkForwardWindow{
+			InSampleStart:    inSample[0],
+			InSampleEnd:      inSample[len(inSample)-1],
+			OutOfSampleStart: outOfSample[0],
+			OutOfSampleEnd:   outOfSample[len(outOfSample)-1],
+		}
+		ranked := bt.Optimize(feed, opt, w.InSampleStart, w.InSampleEnd)
+		if len(ranked) == 0 {
+			break
+		}
+		best := ranked[0]
+		w.Params = best.Params
+		w.InSample = best.Score
+		w.InSampleObjective = best.Objective
+
+		e := BacktestEngine{config: bt.config}
+		e.config.portfolio = nil
+		e.RegisterAlgoFactory(opt.Name, func() AlgoStrategy {
+			return opt.Factory(best.Params)
+		})
+		e.RunAlgoBetweenDate(feed, nil, opt.Name, w.OutOfSampleStart, w.OutOfSampleEnd)
+		w.OutOfSample = totalTrades(opt.Name, e.orders).score
+		w.OutOfSampleObjective = opt.objective(w.OutOfSample)
+		if w.InSample.NetPnl > 0 {
+			w.Efficiency = (w.OutOfSample.NetPnl / float64(len(outOfSample))) / (w.InSample.NetPnl / float64(len(inSample)))
+		}
+		orders = append(orders, e.orders...)
+		result.Windows = append(result.Windows, w)
+	}
+
+	bt.orders = orders
+	bt.scores = calculateAlgoScores(bt.orders)
+	result.Score = totalTrades(opt.Name, bt.orders).score
+	return result
+}
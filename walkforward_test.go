@@ -0,0 +1,49 @@
+package malgova
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestWalkForwardWindows(t *testing.T) {
+	days := func(n int) []time.Time {
+		dates := make([]time.Time, n)
+		for i := range dates {
+			dates[i] = testStart.AddDate(0, 0, i)
+		}
+		return dates
+	}
+	// day returns the day number of the date
+	day := func(d time.Time) int {
+		return int(math.Round(d.Sub(testStart).Hours() / 24))
+	}
+	tests := []struct {
+		name string
+		wf   WalkForward
+		days int
+		want string
+	}{
+		{"rolling", WalkForward{InSample: 4, OutOfSample: 2}, 10, "[0-3 4-5] [2-5 6-7] [4-7 8-9]"},
+		{"anchored", WalkForward{InSample: 4, OutOfSample: 2, Anchored: true}, 10, "[0-3 4-5] [0-5 6-7] [0-7 8-9]"},
+		{"shorter last out-of-sample", WalkForward{InSample: 4, OutOfSample: 2}, 9, "[0-3 4-5] [2-5 6-7] [4-7 8-8]"},
+		{"no out-of-sample days", WalkForward{InSample: 4, OutOfSample: 2}, 4, ""},
+		{"no window lengths", WalkForward{}, 10, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ""
+			for i, w := range tt.wf.windows(days(tt.days)) {
+				if i > 0 {
+					got += " "
+				}
+				is, oos := w[0], w[1]
+				got += fmt.Sprintf("[%d-%d %d-%d]", day(is[0]), day(is[len(is)-1]), day(oos[0]), day(oos[len(oos)-1]))
+			}
+			if got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}